@@ -0,0 +1,147 @@
+package baseline
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/summerwind/h2spec/spec"
+)
+
+// Baseline represents a list of test cases that are expected to fail
+// or to be skipped. Each line of a baseline file contains the ID of
+// a test case optionally followed by the reason. Empty lines and
+// lines starting with "#" are ignored.
+//
+//	# Known deviations of our server
+//	http2/5.1/8 Responds with RST_STREAM instead of GOAWAY
+//	hpack/4.2/1
+type Baseline struct {
+	// Reasons maps the ID of a test case to the reason why it is
+	// expected to fail.
+	Reasons map[string]string
+}
+
+// Comparison represents the result of comparing test results with
+// a baseline.
+type Comparison struct {
+	// NewFailures are failed test cases that are not in the baseline.
+	NewFailures []*spec.TestResult
+	// KnownFailures are failed test cases that are in the baseline.
+	KnownFailures []*spec.TestResult
+	// Fixed are passed test cases that are in the baseline.
+	Fixed []*spec.TestResult
+}
+
+// Load reads the baseline file of the specified path.
+func Load(path string) (*Baseline, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	b, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	return b, nil
+}
+
+// Parse reads a baseline from r.
+func Parse(r io.Reader) (*Baseline, error) {
+	b := &Baseline{
+		Reasons: map[string]string{},
+	}
+
+	scanner := bufio.NewScanner(r)
+	num := 0
+	for scanner.Scan() {
+		num += 1
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		id, reason := line, ""
+		i := strings.IndexAny(line, " \t")
+		if i >= 0 {
+			id, reason = line[:i], strings.TrimSpace(line[i:])
+		}
+
+		if strings.Count(id, "/") != 2 {
+			return nil, fmt.Errorf("line %d: invalid test case ID: %s", num, id)
+		}
+
+		b.Reasons[id] = reason
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// Contains returns true if the test case of the specified ID is
+// expected to fail or to be skipped.
+func (b *Baseline) Contains(id string) bool {
+	_, ok := b.Reasons[id]
+	return ok
+}
+
+// Compare compares the results of test cases with this baseline.
+func (b *Baseline) Compare(results []*spec.TestResult) *Comparison {
+	cmp := &Comparison{}
+
+	for _, tr := range results {
+		known := b.Contains(tr.ID())
+
+		if tr.Failed {
+			if known {
+				cmp.KnownFailures = append(cmp.KnownFailures, tr)
+			} else {
+				cmp.NewFailures = append(cmp.NewFailures, tr)
+			}
+		} else if !tr.Skipped && known {
+			cmp.Fixed = append(cmp.Fixed, tr)
+		}
+	}
+
+	return cmp
+}
+
+// Write writes a baseline file that contains all the failed and
+// skipped test cases of the results.
+func Write(results []*spec.TestResult, path string) error {
+	var buf bytes.Buffer
+
+	buf.WriteString("# Test cases expected to fail or to be skipped, generated by h2spec.\n")
+	for _, tr := range results {
+		if !tr.Failed && !tr.Skipped {
+			continue
+		}
+
+		reason := "Skipped"
+		if tr.Failed {
+			reason = "Failed"
+
+			err, ok := tr.Error.(*spec.TestError)
+			if ok {
+				reason = fmt.Sprintf("Failed with %s", err.Actual)
+			}
+		}
+
+		buf.WriteString(fmt.Sprintf("# %s\n", tr.TestCase.Desc))
+		buf.WriteString(fmt.Sprintf("%s %s\n", tr.ID(), reason))
+	}
+
+	return ioutil.WriteFile(path, buf.Bytes(), 0644)
+}
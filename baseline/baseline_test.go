@@ -0,0 +1,48 @@
+package baseline
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	input := `
+# Known deviations
+http2/5.1/8 Responds with RST_STREAM
+hpack/4.2/1
+generic/3.5/1	Tab separated reason
+`
+
+	b, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		id       string
+		contains bool
+		reason   string
+	}{
+		{id: "http2/5.1/8", contains: true, reason: "Responds with RST_STREAM"},
+		{id: "hpack/4.2/1", contains: true, reason: ""},
+		{id: "generic/3.5/1", contains: true, reason: "Tab separated reason"},
+		{id: "http2/5.1/1", contains: false, reason: ""},
+		{id: "http2/5.1", contains: false, reason: ""},
+	}
+
+	for i, tt := range tests {
+		if b.Contains(tt.id) != tt.contains {
+			t.Errorf("#%d contains - expect: %v, got: %v (%s)", i, tt.contains, !tt.contains, tt.id)
+		}
+		if b.Reasons[tt.id] != tt.reason {
+			t.Errorf("#%d reason - expect: %q, got: %q (%s)", i, tt.reason, b.Reasons[tt.id], tt.id)
+		}
+	}
+}
+
+func TestParseInvalidID(t *testing.T) {
+	_, err := Parse(strings.NewReader("http2/5.1\n"))
+	if err == nil {
+		t.Errorf("expect an error for a group ID")
+	}
+}
@@ -1,13 +1,17 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
-	"time"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/summerwind/h2spec"
 	"github.com/summerwind/h2spec/config"
+	"github.com/summerwind/h2spec/probe"
+	"github.com/summerwind/h2spec/reporter"
 )
 
 var (
@@ -16,43 +20,81 @@ var (
 )
 
 func main() {
+	c := defaultConfig()
 	var cmd = &cobra.Command{
 		Use:   "h2spec [spec...]",
 		Short: "Conformance testing tool for HTTP/2 implementation",
 		Long:  "Conformance testing tool for HTTP/2 implementation.",
-		RunE:  run,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return run(cmd, args, c)
+		},
 	}
 
 	cmd.SilenceUsage = true
 	cmd.SilenceErrors = true
 
+	var diffCmd = &cobra.Command{
+		Use:   "diff old.json new.json",
+		Short: "Compare the JSON reports of two runs",
+		Long:  "Compare the JSON reports of two runs and display the test cases whose result changed.",
+		RunE:  diff,
+	}
+	diffCmd.Flags().Bool("help", false, "Display this help and exit")
+	cmd.AddCommand(diffCmd)
+
+	var mergeCmd = &cobra.Command{
+		Use:   "merge report...",
+		Short: "Merge the JSON or JUnit reports of several runs",
+		Long:  "Merge the partial JSON or JUnit reports of several runs, such as the shards of the test suite, into one report.",
+		RunE:  merge,
+	}
+	mergeCmd.Flags().StringP("output", "o", "", "Path for the merged report")
+	mergeCmd.Flags().Bool("help", false, "Display this help and exit")
+	cmd.AddCommand(mergeCmd)
+
+	pc := defaultConfig()
+	var probeCmd = &cobra.Command{
+		Use:   "probe",
+		Short: "Report the capabilities of the server",
+		Long:  "Report the settings, the HPACK encoding, the flow control and the GOAWAY handling of the server.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return probeTarget(cmd, args, pc)
+		},
+	}
+
+	probeFlags := probeCmd.Flags()
+	probeFlags.String("config", "", "Path for configuration file")
+	probeFlags.String("profile", "", "Name of the profile in configuration file (default \"default\")")
+	config.BindFlags(probeFlags, pc, connectionFlags)
+	probeFlags.String("format", "table", "Output format (table or json)")
+	probeFlags.Bool("help", false, "Display this help and exit")
+	cmd.AddCommand(probeCmd)
+
 	flags := cmd.Flags()
-	flags.StringP("host", "h", "127.0.0.1", "Target host")
-	flags.IntP("port", "p", 0, "Target port")
-	flags.StringP("path", "P", "/", "Target path")
-	flags.IntP("timeout", "o", 2, "Time seconds to test timeout")
-	flags.Int("max-header-length", 4000, "Maximum length of HTTP header")
-	flags.StringP("junit-report", "j", "", "Path for JUnit test report")
-	flags.BoolP("strict", "S", false, "Run all test cases including strict test cases")
-	flags.Bool("dryrun", false, "Display only the title of test cases")
-	flags.BoolP("tls", "t", false, "Connect over TLS")
-	flags.StringP("ciphers", "c", "", "List of colon-separated TLS cipher names")
-	flags.BoolP("insecure", "k", false, "Don't verify server's certificate")
-	flags.BoolP("verbose", "v", false, "Output verbose log")
+	flags.String("config", "", "Path for configuration file")
+	flags.String("profile", "", "Name of the profile in configuration file (default \"default\")")
+	config.BindFlags(flags, c, connectionFlags)
+	config.BindFlags(flags, c, runFlags)
 	flags.Bool("version", false, "Display version information and exit")
 	flags.Bool("help", false, "Display this help and exit")
 
-	err := cmd.Execute()
+	// Cobra rejects the arguments of a command that has subcommands
+	// unless they name one of them. The subcommands are removed when
+	// the arguments are sections so that they are passed to run.
+	_, _, err := cmd.Find(os.Args[1:])
 	if err != nil {
-		fmt.Printf("Error: %s", err)
+		cmd.RemoveCommand(diffCmd, mergeCmd, probeCmd)
+	}
+
+	err = cmd.Execute()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
 		os.Exit(1)
 	}
 }
 
-func run(cmd *cobra.Command, args []string) error {
-	flags := cmd.Flags()
-
-	v, err := flags.GetBool("version")
+func run(cmd *cobra.Command, args []string, c *config.Config) error {
+	v, err := cmd.Flags().GetBool("version")
 	if err != nil {
 		return err
 	}
@@ -62,96 +104,106 @@ func run(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
-	host, err := flags.GetString("host")
+	err = config.LoadCommandLine(c, cmd.Flags(), args)
 	if err != nil {
 		return err
 	}
 
-	port, err := flags.GetInt("port")
+	success, err := h2spec.Run(c)
 	if err != nil {
 		return err
 	}
 
-	path, err := flags.GetString("path")
-	if err != nil {
-		return err
+	if !success {
+		os.Exit(1)
 	}
 
-	timeout, err := flags.GetInt("timeout")
-	if err != nil {
-		return err
+	return nil
+}
+
+func diff(cmd *cobra.Command, args []string) error {
+	if len(args) != 2 {
+		return errors.New("Paths for two JSON reports must be specified")
 	}
 
-	maxHeaderLen, err := flags.GetInt("max-header-length")
+	old, err := reporter.LoadJSONReport(args[0])
 	if err != nil {
 		return err
 	}
 
-	junitReport, err := flags.GetString("junit-report")
+	new, err := reporter.LoadJSONReport(args[1])
 	if err != nil {
 		return err
 	}
 
-	strict, err := flags.GetBool("strict")
-	if err != nil {
-		return err
+	d := reporter.DiffJSONReports(old, new)
+	reporter.PrintDiff(d)
+
+	if d.HasRegressions() {
+		os.Exit(1)
 	}
 
-	dryRun, err := flags.GetBool("dryrun")
+	return nil
+}
+
+func merge(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		return errors.New("Paths for the reports to merge must be specified")
+	}
+
+	output, err := cmd.Flags().GetString("output")
 	if err != nil {
 		return err
 	}
 
-	tls, err := flags.GetBool("tls")
+	if output == "" {
+		return errors.New("Path for the merged report must be specified with --output")
+	}
+
+	return reporter.MergeReports(args, output)
+}
+
+func probeTarget(cmd *cobra.Command, args []string, c *config.Config) error {
+	if len(args) > 0 {
+		return fmt.Errorf("Unexpected arguments: %s", strings.Join(args, " "))
+	}
+
+	format, err := cmd.Flags().GetString("format")
 	if err != nil {
 		return err
 	}
 
-	ciphers, err := flags.GetString("ciphers")
+	if format != "table" && format != "json" {
+		return fmt.Errorf("Invalid format: %s", format)
+	}
+
+	err = config.LoadCommandLine(c, cmd.Flags(), nil)
 	if err != nil {
 		return err
 	}
 
-	insecure, err := flags.GetBool("insecure")
+	err = c.Validate()
 	if err != nil {
 		return err
 	}
 
-	verbose, err := flags.GetBool("verbose")
+	r, err := probe.Run(c)
 	if err != nil {
 		return err
 	}
 
-	if port == 0 {
-		if tls {
-			port = 443
-		} else {
-			port = 80
+	if format == "json" {
+		buf, err := json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			return err
 		}
+		fmt.Println(string(buf))
+		return nil
 	}
 
-	c := &config.Config{
-		Host:         host,
-		Port:         port,
-		Path:         path,
-		Timeout:      time.Duration(timeout) * time.Second,
-		MaxHeaderLen: maxHeaderLen,
-		JUnitReport:  junitReport,
-		Strict:       strict,
-		DryRun:       dryRun,
-		TLS:          tls,
-		Ciphers:      ciphers,
-		Insecure:     insecure,
-		Verbose:      verbose,
-		Sections:     args,
-	}
-
-	success, err := h2spec.Run(c)
-	if !success {
-		os.Exit(1)
-	}
+	reporter.Probe(r)
 
-	return err
+	return nil
 }
 
 func version() {
@@ -3,7 +3,6 @@ package main
 import (
 	"fmt"
 	"os"
-	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/summerwind/h2spec"
@@ -16,43 +15,65 @@ var (
 )
 
 func main() {
+	c := &config.Config{
+		Host:         "127.0.0.1",
+		Status:       200,
+		Timeout:      config.DefaultTimeout,
+		MaxHeaderLen: 4000,
+		CertFile:     "server.crt",
+		CertKeyFile:  "server.key",
+		FromPort:     30000,
+	}
+
 	var cmd = &cobra.Command{
 		Use:   "h2specd [spec...]",
 		Short: "Conformance testing tool for HTTP/2 client implementation",
 		Long:  "Conformance testing tool for HTTP/2 client implementation.",
-		RunE:  run,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return run(cmd, args, c)
+		},
 	}
 
 	cmd.SilenceUsage = true
 	cmd.SilenceErrors = true
 
 	flags := cmd.Flags()
-	flags.StringP("host", "h", "127.0.0.1", "Target host")
-	flags.IntP("port", "p", 0, "Target port")
-	flags.IntP("timeout", "o", 2, "Time seconds to test timeout")
-	flags.Int("max-header-length", 4000, "Maximum length of HTTP header")
-	flags.StringP("junit-report", "j", "", "Path for JUnit test report")
-	flags.BoolP("strict", "S", false, "Run all test cases including strict test cases")
-	flags.Bool("dryrun", false, "Display only the title of test cases")
-	flags.BoolP("tls", "t", false, "Connect over TLS")
-	flags.StringP("cert-file", "c", "server.crt", "Servr certificate file")
-	flags.StringP("cert-key-file", "k", "server.key", "Servr certificate key file")
-
-	flags.IntP("from-port", "f", 30000, "The port starting from for client test cases")
-	flags.StringP("exec", "e", "", "Binary or command for http2 client")
-
-	flags.BoolP("verbose", "v", false, "Output verbose log")
+	flags.String("config", "", "Path for configuration file")
+	flags.String("profile", "", "Name of the profile in configuration file (default \"default\")")
+	config.BindFlags(flags, c, []config.Flag{
+		{Name: "host", Shorthand: "h", Usage: "Target host"},
+		{Name: "port", Shorthand: "p", Usage: "Target port"},
+		{Name: "ipv4", Shorthand: "4", Usage: "Listen on IPv4 addresses only"},
+		{Name: "ipv6", Shorthand: "6", Usage: "Listen on IPv6 addresses only"},
+		{Name: "status", Usage: "Status code of the response used in test cases"},
+		{Name: "header", Usage: "Header field added to the response in the form of \"name: value\""},
+		{Name: "body-file", Usage: "Path for the body of the response"},
+		{Name: "timeout", Shorthand: "o", Usage: "Time seconds to test timeout"},
+		{Name: "max-header-length", Usage: "Maximum length of HTTP header"},
+		{Name: "junit-report", Shorthand: "j", Usage: "Path for JUnit test report"},
+		{Name: "json-report", Usage: "Path for JSON test report"},
+		{Name: "compare", Usage: "Path for JSON test report of a previous run to compare with"},
+		{Name: "strict", Shorthand: "S", Usage: "Run all test cases including strict test cases"},
+		{Name: "dryrun", Usage: "Display only the title of test cases"},
+		{Name: "accept-proxy-protocol", Usage: "Accept PROXY protocol header before the handshake"},
+		{Name: "tls", Shorthand: "t", Usage: "Connect over TLS"},
+		{Name: "cert-file", Shorthand: "c", Usage: "Servr certificate file"},
+		{Name: "cert-key-file", Shorthand: "k", Usage: "Servr certificate key file"},
+		{Name: "from-port", Shorthand: "f", Usage: "The port starting from for client test cases"},
+		{Name: "exec", Shorthand: "e", Usage: "Binary or command for http2 client"},
+		{Name: "verbose", Shorthand: "v", Usage: "Output verbose log"},
+	})
 	flags.Bool("version", false, "Display version information and exit")
 	flags.Bool("help", false, "Display this help and exit")
 
 	err := cmd.Execute()
 	if err != nil {
-		fmt.Printf("Error: %s", err)
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
 		os.Exit(1)
 	}
 }
 
-func run(cmd *cobra.Command, args []string) error {
+func run(cmd *cobra.Command, args []string, c *config.Config) error {
 	flags := cmd.Flags()
 
 	v, err := flags.GetBool("version")
@@ -65,96 +86,11 @@ func run(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
-	host, err := flags.GetString("host")
-	if err != nil {
-		return err
-	}
-
-	port, err := flags.GetInt("port")
-	if err != nil {
-		return err
-	}
-
-	timeout, err := flags.GetInt("timeout")
-	if err != nil {
-		return err
-	}
-
-	maxHeaderLen, err := flags.GetInt("max-header-length")
-	if err != nil {
-		return err
-	}
-
-	junitReport, err := flags.GetString("junit-report")
-	if err != nil {
-		return err
-	}
-
-	strict, err := flags.GetBool("strict")
-	if err != nil {
-		return err
-	}
-
-	dryRun, err := flags.GetBool("dryrun")
+	err = config.LoadCommandLine(c, flags, args)
 	if err != nil {
 		return err
 	}
 
-	tls, err := flags.GetBool("tls")
-	if err != nil {
-		return err
-	}
-
-	certFile, err := flags.GetString("cert-file")
-	if err != nil {
-		return err
-	}
-
-	certKeyFile, err := flags.GetString("cert-key-file")
-	if err != nil {
-		return err
-	}
-
-	fromPort, err := flags.GetInt("from-port")
-	if err != nil {
-		return err
-	}
-
-	exec, err := flags.GetString("exec")
-	if err != nil {
-		return err
-	}
-
-	verbose, err := flags.GetBool("verbose")
-	if err != nil {
-		return err
-	}
-
-	if port == 0 {
-		if tls {
-			port = 443
-		} else {
-			port = 80
-		}
-	}
-
-	c := &config.Config{
-		Host:         host,
-		Port:         port,
-		Timeout:      time.Duration(timeout) * time.Second,
-		MaxHeaderLen: maxHeaderLen,
-		JUnitReport:  junitReport,
-		Strict:       strict,
-		DryRun:       dryRun,
-		TLS:          tls,
-		CertFile:     certFile,
-		CertKeyFile:  certKeyFile,
-		Verbose:      verbose,
-		Sections:     args,
-		FromPort:     fromPort,
-		Exec:         exec,
-	}
-
 	return h2spec.RunClientSpec(c)
 }
 
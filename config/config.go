@@ -2,9 +2,39 @@ package config
 
 import (
 	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"hash/fnv"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"path"
+	"strconv"
 	"strings"
 	"time"
+
+	"golang.org/x/net/http2"
+)
+
+// DefaultTimeout is the default timeout of test cases. The timeouts
+// of individual test cases are relative to it.
+const DefaultTimeout = 2 * time.Second
+
+// Modes of the preflight checks.
+const (
+	PreflightOff   = "off"
+	PreflightWarn  = "warn"
+	PreflightAbort = "abort"
+)
+
+// Modes of writing to the connection. WriteModeRandomSplit is followed
+// by the seed of the random split, such as "random-split:42".
+const (
+	WriteModeFrame       = "frame"
+	WriteModeByteByByte  = "byte-by-byte"
+	WriteModeRandomSplit = "random-split"
+	WriteModeCoalesce    = "coalesce"
 )
 
 const (
@@ -15,29 +45,130 @@ const (
 
 // Config represents the configuration of h2spec.
 type Config struct {
-	Host         string
-	Port         int
-	Path         string
-	Timeout      time.Duration
-	MaxHeaderLen int
-	JUnitReport  string
-	Strict       bool
-	DryRun       bool
-	TLS          bool
-	Ciphers      string
-	Insecure     bool
-	Verbose      bool
-	Sections     []string
-	targetMap    map[string]bool
-	CertFile     string
-	CertKeyFile  string
-	Exec         string
-	FromPort     int
+	Host                string        `yaml:"host"`
+	Port                int           `yaml:"port"`
+	LocalAddr           string        `yaml:"local-addr"`
+	IPv4                bool          `yaml:"ipv4"`
+	IPv6                bool          `yaml:"ipv6"`
+	Path                string        `yaml:"path"`
+	ShutdownPath        string        `yaml:"shutdown-path"`
+	Method              string        `yaml:"method"`
+	Authority           string        `yaml:"authority"`
+	Headers             []string      `yaml:"header"`
+	Settings            []string      `yaml:"setting"`
+	WriteMode           string        `yaml:"write-mode"`
+	BodyFile            string        `yaml:"body-file"`
+	Body                []byte        `yaml:"-"`
+	Status              int           `yaml:"status"`
+	Targets             []Target      `yaml:"targets"`
+	Proxy               string        `yaml:"proxy"`
+	ProxyProtocol       string        `yaml:"proxy-protocol"`
+	ProxyProtocolSrc    string        `yaml:"proxy-protocol-source"`
+	ProxyProtocolDst    string        `yaml:"proxy-protocol-destination"`
+	AcceptProxyProtocol bool          `yaml:"accept-proxy-protocol"`
+	Timeout             time.Duration `yaml:"timeout"`
+	Retries             int           `yaml:"retries"`
+	MaxDuration         time.Duration `yaml:"max-duration"`
+	Deadline            time.Time     `yaml:"-"`
+	BeforeEach          string        `yaml:"before-each"`
+	AfterEach           string        `yaml:"after-each"`
+	HealthCheck         bool          `yaml:"health-check"`
+	HealthCheckRetries  int           `yaml:"health-check-retries"`
+	HealthCheckInterval time.Duration `yaml:"health-check-interval"`
+	Preflight           string        `yaml:"preflight"`
+	MaxHeaderLen        int           `yaml:"max-header-length"`
+	JUnitReport         string        `yaml:"junit-report"`
+	JSONReport          string        `yaml:"json-report"`
+	Compare             string        `yaml:"compare"`
+	Timing              bool          `yaml:"timing"`
+	Baseline            string        `yaml:"baseline"`
+	GenBaseline         string        `yaml:"generate-baseline"`
+	Strict              bool          `yaml:"strict"`
+	Shuffle             bool          `yaml:"shuffle"`
+	Seed                int64         `yaml:"seed"`
+	Orders              int           `yaml:"orders"`
+	DryRun              bool          `yaml:"dryrun"`
+	Stress              bool          `yaml:"stress"`
+	TLS                 bool          `yaml:"tls"`
+	Ciphers             string        `yaml:"ciphers"`
+	TLSMin              string        `yaml:"tls-min"`
+	TLSMax              string        `yaml:"tls-max"`
+	SNI                 string        `yaml:"sni"`
+	Insecure            bool          `yaml:"insecure"`
+	ClientCertFile      string        `yaml:"client-cert"`
+	ClientKeyFile       string        `yaml:"client-key"`
+	CAFile              string        `yaml:"ca-file"`
+	Verbose             bool          `yaml:"verbose"`
+	Sections            []string      `yaml:"sections"`
+	RerunFailed         string        `yaml:"rerun-failed"`
+	Shard               string        `yaml:"shard"`
+	targetMap           map[string]bool
+	patterns            []string
+	exclusions          []string
+	shardIndex          int
+	shardCount          int
+	CertFile            string `yaml:"cert-file"`
+	CertKeyFile         string `yaml:"cert-key-file"`
+	Exec                string `yaml:"exec"`
+	FromPort            int    `yaml:"from-port"`
+}
+
+// Target represents the request target used by the test cases that
+// match one of the sections in Tests.
+type Target struct {
+	Tests    []string `yaml:"tests"`
+	Path     string   `yaml:"path"`
+	Method   string   `yaml:"method"`
+	Settings []string `yaml:"setting"`
 }
 
 // Addr returns the string concatenated with hostname and port number.
+// IPv6 literals are enclosed in brackets.
 func (c *Config) Addr() string {
-	return fmt.Sprintf("%s:%d", c.Host, c.Port)
+	return net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+}
+
+// Network returns the network used to connect to or listen on the
+// host, "tcp4" or "tcp6" if the address family is forced.
+func (c *Config) Network() string {
+	switch {
+	case c.IPv4:
+		return "tcp4"
+	case c.IPv6:
+		return "tcp6"
+	}
+	return "tcp"
+}
+
+// Dialer returns the dialer used to connect to the host, bound to the
+// local address if it is specified.
+func (c *Config) Dialer() *net.Dialer {
+	d := &net.Dialer{Timeout: c.Timeout}
+	if ip := net.ParseIP(c.LocalAddr); ip != nil {
+		d.LocalAddr = &net.TCPAddr{IP: ip}
+	}
+	return d
+}
+
+// IsLocalTarget returns true if the target host resolves to loopback
+// addresses only.
+func (c *Config) IsLocalTarget() bool {
+	ips := []net.IP{net.ParseIP(c.Host)}
+	if ips[0] == nil {
+		var err error
+		ips, err = net.LookupIP(c.Host)
+		if err != nil || len(ips) == 0 {
+			return false
+		}
+	}
+
+	for _, ip := range ips {
+		if !ip.IsLoopback() {
+			return false
+		}
+	}
+
+	return true
 }
 
 func (c *Config) Scheme() string {
@@ -48,56 +179,34 @@ func (c *Config) Scheme() string {
 	}
 }
 
+// legacyCipherSuiteNames maps the names of cipher suites that were
+// accepted by earlier versions of h2spec to their standard names.
+var legacyCipherSuiteNames = map[string]string{
+	"TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305":   "TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256",
+	"TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305": "TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256",
+}
+
+// CiphersuiteByName returns the ID of the cipher suite of the
+// specified standard name, or 0 if the name is unknown. Both secure
+// and insecure cipher suites implemented by crypto/tls are accepted.
 func CiphersuiteByName(name string) uint16 {
-	switch name {
-	case "TLS_RSA_WITH_RC4_128_SHA":
-		return tls.TLS_RSA_WITH_RC4_128_SHA
-	case "TLS_RSA_WITH_3DES_EDE_CBC_SHA":
-		return tls.TLS_RSA_WITH_3DES_EDE_CBC_SHA
-	case "TLS_RSA_WITH_AES_128_CBC_SHA":
-		return tls.TLS_RSA_WITH_AES_128_CBC_SHA
-	case "TLS_RSA_WITH_AES_128_CBC_SHA256":
-		return tls.TLS_RSA_WITH_AES_128_CBC_SHA256
-	case "TLS_RSA_WITH_AES_256_GCM_SHA384":
-		return tls.TLS_RSA_WITH_AES_256_GCM_SHA384
-	case "TLS_ECDHE_ECDSA_WITH_RC4_128_SHA":
-		return tls.TLS_ECDHE_ECDSA_WITH_RC4_128_SHA
-	case "TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA":
-		return tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA
-	case "TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA":
-		return tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA
-	case "TLS_ECDHE_RSA_WITH_RC4_128_SHA":
-		return tls.TLS_ECDHE_RSA_WITH_RC4_128_SHA
-	case "TLS_ECDHE_RSA_WITH_3DES_EDE_CBC_SHA":
-		return tls.TLS_ECDHE_RSA_WITH_3DES_EDE_CBC_SHA
-	case "TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA":
-		return tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA
-	case "TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA":
-		return tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA
-	case "TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA256":
-		return tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA256
-	case "TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA256":
-		return tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA256
-	case "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256":
-		return tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256
-	case "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256":
-		return tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256
-	case "TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384":
-		return tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384
-	case "TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384":
-		return tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384
-	case "TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305":
-		return tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305
-	case "TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305":
-		return tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305
+	if std, ok := legacyCipherSuiteNames[name]; ok {
+		name = std
+	}
+
+	suites := append(tls.CipherSuites(), tls.InsecureCipherSuites()...)
+	for _, suite := range suites {
+		if suite.Name == name {
+			return suite.ID
+		}
 	}
+
 	return 0
 }
 
 // Decode the user-defined list of allowed cipher suites from string
-// representation.
-// TODO: now Golang doesn't provide a way to convert ciphersuite name to ID,
-// thus manual implementation is required.
+// representation. TLS 1.3 cipher suites are accepted, but they are
+// not configurable in crypto/tls and are always enabled.
 func (c *Config) GetCiphersuites() []uint16 {
 	var ids []uint16
 
@@ -111,6 +220,14 @@ func (c *Config) GetCiphersuites() []uint16 {
 	return ids
 }
 
+// tlsVersions maps the names of TLS versions to their values.
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
 // TLSConfig returns a tls.Config based on the configuration of h2spec.
 func (c *Config) TLSConfig() (*tls.Config, error) {
 	if !c.TLS {
@@ -119,7 +236,10 @@ func (c *Config) TLSConfig() (*tls.Config, error) {
 
 	config := tls.Config{
 		InsecureSkipVerify: c.Insecure,
-		CipherSuites: c.GetCiphersuites(),
+		CipherSuites:       c.GetCiphersuites(),
+		MinVersion:         tlsVersions[c.TLSMin],
+		MaxVersion:         tlsVersions[c.TLSMax],
+		ServerName:         c.SNI,
 	}
 
 	if config.NextProtos == nil {
@@ -134,70 +254,536 @@ func (c *Config) TLSConfig() (*tls.Config, error) {
 		config.Certificates = []tls.Certificate{cert}
 	}
 
+	if c.ClientCertFile != "" && c.ClientKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.ClientCertFile, c.ClientKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	if c.CAFile != "" {
+		pem, err := ioutil.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("No certificate found in %s", c.CAFile)
+		}
+		config.RootCAs = pool
+	}
+
 	return &config, nil
 }
 
+// Validate returns an error if the configuration contains an invalid
+// value.
+func (c *Config) Validate() error {
+	if c.Host == "" {
+		return errors.New("Host must not be empty")
+	}
+
+	if c.Port < 1 || c.Port > 65535 {
+		return fmt.Errorf("Invalid port: %d", c.Port)
+	}
+
+	if c.IPv4 && c.IPv6 {
+		return errors.New("IPv4 and IPv6 must not be forced at the same time")
+	}
+
+	if c.LocalAddr != "" {
+		ip := net.ParseIP(c.LocalAddr)
+		if ip == nil {
+			return fmt.Errorf("Invalid local address: %s", c.LocalAddr)
+		}
+		if (c.IPv4 && ip.To4() == nil) || (c.IPv6 && ip.To4() != nil) {
+			return fmt.Errorf("Local address does not match the address family: %s", c.LocalAddr)
+		}
+	}
+
+	if c.FromPort < 0 || c.FromPort > 65535 {
+		return fmt.Errorf("Invalid port: %d", c.FromPort)
+	}
+
+	if c.Timeout <= 0 {
+		return fmt.Errorf("Invalid timeout: %s", c.Timeout)
+	}
+
+	if c.Retries < 0 {
+		return fmt.Errorf("Invalid number of retries: %d", c.Retries)
+	}
+
+	if c.MaxDuration < 0 {
+		return fmt.Errorf("Invalid maximum duration: %s", c.MaxDuration)
+	}
+
+	if c.Orders < 0 {
+		return fmt.Errorf("Invalid number of orders: %d", c.Orders)
+	}
+
+	if c.HealthCheckRetries < 0 {
+		return fmt.Errorf("Invalid number of health check retries: %d", c.HealthCheckRetries)
+	}
+
+	if c.HealthCheckInterval < 0 {
+		return fmt.Errorf("Invalid health check interval: %s", c.HealthCheckInterval)
+	}
+
+	_, _, err := ParseWriteMode(c.WriteMode)
+	if err != nil {
+		return err
+	}
+
+	switch c.Preflight {
+	case "", PreflightOff, PreflightWarn, PreflightAbort:
+	default:
+		return fmt.Errorf("Invalid preflight mode: %s", c.Preflight)
+	}
+
+	if c.MaxHeaderLen <= 0 {
+		return fmt.Errorf("Invalid maximum header length: %d", c.MaxHeaderLen)
+	}
+
+	if (c.ClientCertFile == "") != (c.ClientKeyFile == "") {
+		return errors.New("Both client certificate and key must be specified")
+	}
+
+	if c.Proxy != "" {
+		u, err := url.Parse(c.Proxy)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "socks5") || u.Hostname() == "" {
+			return fmt.Errorf("Invalid proxy: %s", c.Proxy)
+		}
+	}
+
+	if c.ProxyProtocol != "" && c.ProxyProtocol != "v1" && c.ProxyProtocol != "v2" {
+		return fmt.Errorf("Invalid PROXY protocol version: %s", c.ProxyProtocol)
+	}
+
+	for _, addr := range []string{c.ProxyProtocolSrc, c.ProxyProtocolDst} {
+		if addr == "" {
+			continue
+		}
+
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil || net.ParseIP(host) == nil || !isNumber(port) {
+			return fmt.Errorf("Invalid PROXY protocol address: %s", addr)
+		}
+	}
+
+	if c.Status != 0 && (c.Status < 100 || c.Status > 999) {
+		return fmt.Errorf("Invalid status: %d", c.Status)
+	}
+
+	for _, header := range c.Headers {
+		_, _, err := ParseHeader(header)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, setting := range c.Settings {
+		_, err := ParseSetting(setting)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, v := range []string{c.TLSMin, c.TLSMax} {
+		if _, ok := tlsVersions[v]; v != "" && !ok {
+			return fmt.Errorf("Invalid TLS version: %s", v)
+		}
+	}
+
+	if c.TLSMin != "" && c.TLSMax != "" && tlsVersions[c.TLSMin] > tlsVersions[c.TLSMax] {
+		return fmt.Errorf("Minimum TLS version %s is greater than maximum version %s", c.TLSMin, c.TLSMax)
+	}
+
+	if c.Ciphers != "" {
+		for _, name := range strings.Split(c.Ciphers, ":") {
+			if CiphersuiteByName(name) == 0 {
+				return fmt.Errorf("Unknown cipher suite: %s", name)
+			}
+		}
+	}
+
+	for _, section := range c.Sections {
+		if !validSelector(section) {
+			return fmt.Errorf("Invalid section: %s", section)
+		}
+	}
+
+	if c.Shard != "" {
+		_, _, err := ParseShard(c.Shard)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, target := range c.Targets {
+		if len(target.Tests) == 0 {
+			return errors.New("Target must have at least one section in tests")
+		}
+
+		for _, section := range target.Tests {
+			if !validSection(section) {
+				return fmt.Errorf("Invalid section: %s", section)
+			}
+		}
+
+		if target.Path != "" && !strings.HasPrefix(target.Path, "/") {
+			return fmt.Errorf("Invalid target path: %s", target.Path)
+		}
+
+		for _, setting := range target.Settings {
+			_, err := ParseSetting(setting)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// ForTest returns the configuration used by the test case of the
+// specified ID. If targets match the ID, the path and method of the
+// most specific one are used instead of the default ones, and its
+// settings take precedence over the default ones.
+func (c *Config) ForTest(id string) *Config {
+	var target *Target
+	matched := ""
+
+	for i, t := range c.Targets {
+		for _, section := range t.Tests {
+			if len(section) > len(matched) && matchSection(section, id) {
+				target = &c.Targets[i]
+				matched = section
+			}
+		}
+	}
+
+	if target == nil {
+		return c
+	}
+
+	tc := *c
+	if target.Path != "" {
+		tc.Path = target.Path
+	}
+	if target.Method != "" {
+		tc.Method = target.Method
+	}
+	if len(target.Settings) > 0 {
+		tc.Settings = append(append([]string{}, c.Settings...), target.Settings...)
+	}
+
+	return &tc
+}
+
+// WithTimeout returns a copy of the configuration that uses the
+// timeout of a test case. The timeout is relative to DefaultTimeout
+// and is scaled with the configured timeout, so that a longer timeout
+// for a slow target also extends the timeouts of test cases. The
+// configuration itself is returned if the timeout is zero.
+func (c *Config) WithTimeout(timeout time.Duration) *Config {
+	if timeout == 0 {
+		return c
+	}
+
+	tc := *c
+	tc.Timeout = time.Duration(float64(c.Timeout) * float64(timeout) / float64(DefaultTimeout))
+	return &tc
+}
+
+// DeadlineExceeded returns true if the time budget of the run set by
+// the maximum duration has run out.
+func (c *Config) DeadlineExceeded() bool {
+	return !c.Deadline.IsZero() && time.Now().After(c.Deadline)
+}
+
+// matchSection returns true if the ID is the section itself or is
+// included in the section.
+func matchSection(section, id string) bool {
+	if id == section {
+		return true
+	}
+	return strings.HasPrefix(id, section+"/") || strings.HasPrefix(id, section+".")
+}
+
+// LoadBody reads the body file into Body. The body is sent within the
+// flow-control windows of the server.
+func (c *Config) LoadBody() error {
+	if c.BodyFile == "" {
+		return nil
+	}
+
+	body, err := ioutil.ReadFile(c.BodyFile)
+	if err != nil {
+		return err
+	}
+
+	c.Body = body
+	return nil
+}
+
+// clientSettings maps the names of the settings that can be sent in
+// the client handshake to their IDs.
+var clientSettings = map[string]http2.SettingID{
+	"HEADER_TABLE_SIZE":    http2.SettingHeaderTableSize,
+	"ENABLE_PUSH":          http2.SettingEnablePush,
+	"MAX_FRAME_SIZE":       http2.SettingMaxFrameSize,
+	"MAX_HEADER_LIST_SIZE": http2.SettingMaxHeaderListSize,
+}
+
+// ParseSetting parses a setting string in the form of "NAME=VALUE".
+// The name may be prefixed with "SETTINGS_".
+func ParseSetting(setting string) (http2.Setting, error) {
+	comps := strings.SplitN(setting, "=", 2)
+	name := strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(comps[0])), "SETTINGS_")
+
+	id, ok := clientSettings[name]
+	if len(comps) != 2 || !ok {
+		return http2.Setting{}, fmt.Errorf("Invalid setting: %s", setting)
+	}
+
+	val, err := strconv.ParseUint(strings.TrimSpace(comps[1]), 10, 32)
+	if err != nil {
+		return http2.Setting{}, fmt.Errorf("Invalid setting: %s", setting)
+	}
+
+	s := http2.Setting{ID: id, Val: uint32(val)}
+	if s.Valid() != nil {
+		return http2.Setting{}, fmt.Errorf("Invalid value of %s: %d", name, val)
+	}
+
+	return s, nil
+}
+
+// ClientSettings returns the settings sent in the client handshake in
+// addition to the initial window size. If a setting is specified more
+// than once, the last value is used.
+func (c *Config) ClientSettings() []http2.Setting {
+	var settings []http2.Setting
+	index := map[http2.SettingID]int{}
+
+	for _, setting := range c.Settings {
+		s, err := ParseSetting(setting)
+		if err != nil {
+			continue
+		}
+
+		i, ok := index[s.ID]
+		if ok {
+			settings[i] = s
+			continue
+		}
+
+		index[s.ID] = len(settings)
+		settings = append(settings, s)
+	}
+
+	return settings
+}
+
+// ParseWriteMode parses a write mode string and returns the mode and
+// the seed of the random split. An empty string is the frame mode.
+func ParseWriteMode(writeMode string) (string, int64, error) {
+	comps := strings.SplitN(writeMode, ":", 2)
+	mode := comps[0]
+
+	switch mode {
+	case "":
+		return WriteModeFrame, 0, nil
+	case WriteModeFrame, WriteModeByteByByte, WriteModeCoalesce:
+		if len(comps) == 1 {
+			return mode, 0, nil
+		}
+	case WriteModeRandomSplit:
+		if len(comps) == 2 {
+			seed, err := strconv.ParseInt(comps[1], 10, 64)
+			if err == nil {
+				return mode, seed, nil
+			}
+		}
+	}
+
+	return "", 0, fmt.Errorf("Invalid write mode: %s", writeMode)
+}
+
+// ParseHeader splits a header string in the form of "name: value"
+// into its lower-cased name and value.
+func ParseHeader(header string) (string, string, error) {
+	comps := strings.SplitN(header, ":", 2)
+	name := strings.ToLower(strings.TrimSpace(comps[0]))
+
+	if len(comps) != 2 || name == "" || strings.ContainsAny(name, " \t") {
+		return "", "", fmt.Errorf("Invalid header: %s", header)
+	}
+
+	return name, strings.TrimSpace(comps[1]), nil
+}
+
+// validSection returns true if the section string is a key optionally
+// followed by a section number and a test case number, such as
+// "http2/5.1.2/1".
+func validSection(section string) bool {
+	comps := strings.Split(section, "/")
+	if len(comps) > 3 || comps[0] == "" {
+		return false
+	}
+
+	if len(comps) > 1 {
+		for _, num := range strings.Split(comps[1], ".") {
+			if !isNumber(num) {
+				return false
+			}
+		}
+	}
+
+	if len(comps) > 2 && !isNumber(comps[2]) {
+		return false
+	}
+
+	return true
+}
+
+// validSelector returns true if the selector of test cases to run is
+// a valid section string or glob pattern, optionally prefixed with
+// "!" to exclude the matching test cases.
+func validSelector(selector string) bool {
+	selector = strings.TrimPrefix(selector, "!")
+	if !isPattern(selector) {
+		return validSection(selector)
+	}
+
+	comps := strings.Split(selector, "/")
+	if len(comps) > 3 || comps[0] == "" {
+		return false
+	}
+
+	_, err := path.Match(selector, "")
+	return err == nil
+}
+
+// isPattern returns true if the selector contains a glob pattern.
+func isPattern(selector string) bool {
+	return strings.ContainsAny(selector, "*?[\\")
+}
+
+func isNumber(s string) bool {
+	_, err := strconv.ParseUint(s, 10, 32)
+	return err == nil
+}
+
 // RunMode returns a run mode of specified the section number.
-// This is used to decide whether to run test cases.
+// This is used to decide whether to run test cases. RunModeGroup
+// means that some of the test cases of the group may be run.
 func (c *Config) RunMode(section string) int {
 	if c.targetMap == nil {
 		c.buildTargetMap()
 	}
 
-	if len(c.targetMap) == 0 {
-		return RunModeAll
+	keys := sectionKeys(section)
+	if keys == nil {
+		return RunModeNone
 	}
 
-	comps := strings.Split(section, "/")
-	compLen := len(comps)
+	result := RunModeAll
+	if len(c.targetMap) > 0 || len(c.patterns) > 0 {
+		result = RunModeNone
 
-	if compLen == 0 || compLen > 3 {
-		return RunModeNone
-	}
+		for _, key := range keys {
+			val, ok := c.targetMap[key]
+			if ok {
+				if val {
+					result = RunModeAll
+					break
+				}
+				result = RunModeGroup
+			} else {
+				result = RunModeNone
+			}
+		}
 
-	keys := []string{comps[0]}
+		for _, pattern := range c.patterns {
+			if result == RunModeAll {
+				break
+			}
 
-	if compLen > 1 {
-		nums := strings.Split(comps[1], ".")
-		for i, _ := range nums {
-			key := fmt.Sprintf("%s/%s", comps[0], strings.Join(nums[:i+1], "."))
-			keys = append(keys, key)
+			if matchPattern(pattern, keys) {
+				result = RunModeAll
+			} else if mayMatchDescendant(pattern, section) {
+				result = RunModeGroup
+			}
 		}
 	}
 
-	if compLen > 2 {
-		keys = append(keys, section)
+	if result == RunModeNone {
+		return result
 	}
 
-	var result int
-	for _, key := range keys {
-		val, ok := c.targetMap[key]
-		if ok {
-			if val {
-				return RunModeAll
-			}
+	// Test cases of other shards are not run. Groups are left to the
+	// test cases they include.
+	if c.shardCount > 0 && strings.Count(section, "/") == 2 {
+		if !inShard(section, c.shardIndex, c.shardCount) {
+			return RunModeNone
+		}
+	}
+
+	for _, pattern := range c.exclusions {
+		if matchPattern(pattern, keys) {
+			return RunModeNone
+		}
+
+		if mayMatchDescendant(pattern, section) {
 			result = RunModeGroup
-		} else {
-			result = RunModeNone
 		}
 	}
 
 	return result
 }
 
+// SetSections replaces the sections of test cases to run.
+func (c *Config) SetSections(sections ...string) {
+	c.Sections = sections
+	c.targetMap = nil
+}
+
 func (c *Config) buildTargetMap() {
 	c.targetMap = map[string]bool{}
+	c.patterns = nil
+	c.exclusions = nil
+
+	// Invalid shards are rejected by Validate.
+	c.shardIndex, c.shardCount = 0, 0
+	if c.Shard != "" {
+		i, n, err := ParseShard(c.Shard)
+		if err == nil {
+			c.shardIndex, c.shardCount = i, n
+		}
+	}
 
 	for _, section := range c.Sections {
-		comps := strings.Split(section, "/")
-		compLen := len(comps)
+		// Invalid section strings are rejected by Validate.
+		if !validSelector(section) {
+			continue
+		}
+
+		if strings.HasPrefix(section, "!") {
+			c.exclusions = append(c.exclusions, section[1:])
+			continue
+		}
 
-		// Validate the format of the section string.
-		if compLen == 0 || compLen > 3 {
-			fmt.Printf("Invalid section: %s", section)
+		if isPattern(section) {
+			c.patterns = append(c.patterns, section)
 			continue
 		}
 
+		comps := strings.Split(section, "/")
+		compLen := len(comps)
+
 		// Check the section string is root section or not.
 		if compLen == 1 {
 			c.targetMap[comps[0]] = true
@@ -222,6 +808,93 @@ func (c *Config) buildTargetMap() {
 	}
 }
 
+// ParseShard parses a shard string in the form of "INDEX/COUNT" and
+// returns the index, starting from 1, and the number of shards.
+func ParseShard(shard string) (int, int, error) {
+	comps := strings.SplitN(shard, "/", 2)
+	if len(comps) == 2 {
+		i, err1 := strconv.Atoi(comps[0])
+		n, err2 := strconv.Atoi(comps[1])
+		if err1 == nil && err2 == nil && i >= 1 && i <= n {
+			return i, n, nil
+		}
+	}
+
+	return 0, 0, fmt.Errorf("Invalid shard: %s", shard)
+}
+
+// inShard returns true if the test case of the ID belongs to the
+// shard of the index. Test cases are assigned to shards by the hash
+// of their ID, so that the assignment does not depend on the other
+// test cases.
+func inShard(id string, index, count int) bool {
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return int(h.Sum32()%uint32(count)) == index-1
+}
+
+// sectionKeys returns the IDs of the section and its parent groups,
+// from the root group to the section itself. It returns nil if the
+// section is invalid.
+func sectionKeys(section string) []string {
+	comps := strings.Split(section, "/")
+	compLen := len(comps)
+
+	if compLen == 0 || compLen > 3 {
+		return nil
+	}
+
+	keys := []string{comps[0]}
+
+	if compLen > 1 {
+		nums := strings.Split(comps[1], ".")
+		for i, _ := range nums {
+			key := fmt.Sprintf("%s/%s", comps[0], strings.Join(nums[:i+1], "."))
+			keys = append(keys, key)
+		}
+	}
+
+	if compLen > 2 {
+		keys = append(keys, section)
+	}
+
+	return keys
+}
+
+// matchPattern returns true if the pattern matches the section or one
+// of its parent groups given as keys.
+func matchPattern(pattern string, keys []string) bool {
+	for _, key := range keys {
+		ok, _ := path.Match(pattern, key)
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// mayMatchDescendant returns true if the pattern may match a group or
+// a test case included in the section. It errs on the side of true
+// for glob patterns.
+func mayMatchDescendant(pattern, section string) bool {
+	// Test cases have no descendants.
+	if strings.Count(section, "/") > 1 {
+		return false
+	}
+
+	if !isPattern(pattern) {
+		for _, key := range sectionKeys(pattern) {
+			if key == section && key != pattern {
+				return true
+			}
+		}
+		return false
+	}
+
+	prefix := pattern[:strings.IndexAny(pattern, "*?[\\")]
+	return strings.HasPrefix(section, prefix) || strings.HasPrefix(prefix, section)
+}
+
 func (c *Config) IsBrowserMode() bool {
 	return c.Exec == ""
 }
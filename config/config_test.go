@@ -1,6 +1,17 @@
 package config
 
-import "testing"
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+	"golang.org/x/net/http2"
+)
 
 func TestRunMode(t *testing.T) {
 	tests := []struct {
@@ -74,6 +85,27 @@ func TestRunMode(t *testing.T) {
 		{sections: []string{"http2/5.1.2/1", "http2"}, target: "http2/5.2", mode: RunModeAll},
 		{sections: []string{"http2/5.1.2/1", "http2"}, target: "http2/5.1.3", mode: RunModeAll},
 		{sections: []string{"http2/5.1.2/1", "http2"}, target: "http2/5.1.2/2", mode: RunModeAll},
+
+		{sections: []string{"!http2/5.1"}, target: "http2", mode: RunModeGroup},
+		{sections: []string{"!http2/5.1"}, target: "http2/5", mode: RunModeGroup},
+		{sections: []string{"!http2/5.1"}, target: "http2/5.1", mode: RunModeNone},
+		{sections: []string{"!http2/5.1"}, target: "http2/5.1.2/1", mode: RunModeNone},
+		{sections: []string{"!http2/5.1"}, target: "http2/5.2", mode: RunModeAll},
+		{sections: []string{"!http2/5.1"}, target: "hpack", mode: RunModeAll},
+
+		{sections: []string{"http2", "!http2/6.9.*"}, target: "http2/6.9", mode: RunModeGroup},
+		{sections: []string{"http2", "!http2/6.9.*"}, target: "http2/6.9/1", mode: RunModeAll},
+		{sections: []string{"http2", "!http2/6.9.*"}, target: "http2/6.9.1", mode: RunModeNone},
+		{sections: []string{"http2", "!http2/6.9.*"}, target: "http2/6.9.1/2", mode: RunModeNone},
+		{sections: []string{"http2", "!http2/6.9.*"}, target: "http2/6.8", mode: RunModeAll},
+		{sections: []string{"http2", "!http2/6.9.*"}, target: "hpack", mode: RunModeNone},
+
+		{sections: []string{"hpack/*/2"}, target: "hpack", mode: RunModeGroup},
+		{sections: []string{"hpack/*/2"}, target: "hpack/2.3", mode: RunModeGroup},
+		{sections: []string{"hpack/*/2"}, target: "hpack/2.3/2", mode: RunModeAll},
+		{sections: []string{"hpack/*/2"}, target: "hpack/2.3/1", mode: RunModeNone},
+		{sections: []string{"hpack/*/2"}, target: "http2", mode: RunModeNone},
+		{sections: []string{"hpack/*/2", "!hpack/2.3/*"}, target: "hpack/2.3/2", mode: RunModeNone},
 	}
 
 	for i, tt := range tests {
@@ -87,3 +119,369 @@ func TestRunMode(t *testing.T) {
 		}
 	}
 }
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		sections  []string
+		ciphers   string
+		localAddr string
+		ipv6      bool
+		valid     bool
+	}{
+		{sections: []string{"http2"}, valid: true},
+		{sections: []string{"http2/5.1.2"}, valid: true},
+		{sections: []string{"http2/5.1.2/1"}, valid: true},
+		{sections: []string{"http2/5.1.2/1/1"}, valid: false},
+		{sections: []string{"http2/5.a"}, valid: false},
+		{sections: []string{"http2/5."}, valid: false},
+		{sections: []string{"http2/5/x"}, valid: false},
+		{sections: []string{"/5"}, valid: false},
+		{sections: []string{"!http2/5.1"}, valid: true},
+		{sections: []string{"!http2/6.9.*"}, valid: true},
+		{sections: []string{"hpack/*/2"}, valid: true},
+		{sections: []string{"hpack/[2/1"}, valid: false},
+		{sections: []string{"!http2/5.a"}, valid: false},
+		{ciphers: "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", valid: true},
+		{ciphers: "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256:UNKNOWN", valid: false},
+		{ciphers: "TLS_AES_128_GCM_SHA256", valid: true},
+		{ciphers: "TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305", valid: true},
+		{localAddr: "192.0.2.1", valid: true},
+		{localAddr: "2001:db8::1", ipv6: true, valid: true},
+		{localAddr: "192.0.2.1", ipv6: true, valid: false},
+		{localAddr: "localhost", valid: false},
+	}
+
+	for i, tt := range tests {
+		c := Config{
+			Host:         "127.0.0.1",
+			Port:         80,
+			Timeout:      2 * time.Second,
+			MaxHeaderLen: 4000,
+			Ciphers:      tt.ciphers,
+			Sections:     tt.sections,
+			LocalAddr:    tt.localAddr,
+			IPv6:         tt.ipv6,
+		}
+
+		err := c.Validate()
+		if tt.valid != (err == nil) {
+			t.Errorf("#%d valid - expect: %v, got: %v", i, tt.valid, err)
+		}
+	}
+}
+
+func TestAddr(t *testing.T) {
+	tests := []struct {
+		host string
+		addr string
+	}{
+		{host: "127.0.0.1", addr: "127.0.0.1:8080"},
+		{host: "localhost", addr: "localhost:8080"},
+		{host: "::1", addr: "[::1]:8080"},
+		{host: "fe80::1%eth0", addr: "[fe80::1%eth0]:8080"},
+	}
+
+	for _, tt := range tests {
+		c := Config{Host: tt.host, Port: 8080}
+		if c.Addr() != tt.addr {
+			t.Errorf("%s - expect: %s, got: %s", tt.host, tt.addr, c.Addr())
+		}
+	}
+}
+
+func TestBindFlags(t *testing.T) {
+	c := Config{Host: "127.0.0.1", Timeout: 2 * time.Second}
+
+	fs := pflag.NewFlagSet("h2spec", pflag.ContinueOnError)
+	BindFlags(fs, &c, []Flag{
+		{Name: "host", Shorthand: "h"},
+		{Name: "port", Shorthand: "p"},
+		{Name: "timeout", Shorthand: "o"},
+		{Name: "header"},
+		{Name: "tls", Shorthand: "t"},
+		{Name: "seed"},
+	})
+
+	if def := fs.Lookup("timeout").DefValue; def != "2" {
+		t.Errorf("timeout default - expect: 2, got: %s", def)
+	}
+
+	err := fs.Parse([]string{"-p", "8443", "-o", "5", "--header", "a: 1", "--header", "b: 2", "-t", "--seed", "42"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := Config{
+		Host:    "127.0.0.1",
+		Port:    8443,
+		Timeout: 5 * time.Second,
+		Headers: []string{"a: 1", "b: 2"},
+		TLS:     true,
+		Seed:    42,
+	}
+	if !reflect.DeepEqual(c, expected) {
+		t.Errorf("expect: %+v, got: %+v", expected, c)
+	}
+}
+
+func TestLoadProfile(t *testing.T) {
+	data := `
+profiles:
+  default:
+    host: localhost
+  staging:
+    host: staging.example.com
+    port: 443
+    tls: true
+    timeout: 5
+    health-check-interval: 500ms
+    header:
+      - "x-profile: staging"
+    sections:
+      - http2/5
+`
+
+	path := filepath.Join(t.TempDir(), "h2spec.yaml")
+	err := ioutil.WriteFile(path, []byte(data), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := Config{Host: "127.0.0.1", Port: 8080, Headers: []string{"x-flag: 1"}}
+	err = LoadProfile(&c, path, "staging", func(option string) bool {
+		return option == "port" || option == "header"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Host != "staging.example.com" || !c.TLS || c.Timeout != 5*time.Second {
+		t.Errorf("unexpected config: %+v", c)
+	}
+	if c.HealthCheckInterval != 500*time.Millisecond {
+		t.Errorf("health-check-interval - expect: 500ms, got: %s", c.HealthCheckInterval)
+	}
+	if c.Port != 8080 {
+		t.Errorf("port - expect: 8080, got: %d", c.Port)
+	}
+	if len(c.Headers) != 1 || c.Headers[0] != "x-flag: 1" {
+		t.Errorf("header - expect: [x-flag: 1], got: %v", c.Headers)
+	}
+	if len(c.Sections) != 1 || c.Sections[0] != "http2/5" {
+		t.Errorf("sections - expect: [http2/5], got: %v", c.Sections)
+	}
+
+	err = LoadProfile(&c, path, "", nil)
+	if err != nil || c.Host != "localhost" {
+		t.Errorf("default profile - expect: localhost, got: %s (%v)", c.Host, err)
+	}
+
+	err = LoadProfile(&c, path, "production", nil)
+	if err == nil {
+		t.Errorf("expected an error for unknown profile")
+	}
+
+	// Errors refer to the lines of the file.
+	invalid := []struct {
+		data string
+		line string
+	}{
+		{data: "profiles:\n  default:\n    hots: localhost\n", line: "line 3"},
+		{data: "profiles:\n  default:\n    host: localhost\n    timeout: abc\n", line: "line 4"},
+		{data: "profiles:\n  default:\n    targets:\n      - tests: [http2/5]\n        paht: /\n", line: "line 5"},
+	}
+
+	for i, tt := range invalid {
+		err = ioutil.WriteFile(path, []byte(tt.data), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = LoadProfile(&c, path, "", nil)
+		if err == nil || !strings.Contains(err.Error(), tt.line) {
+			t.Errorf("#%d - expect an error on %s, got: %v", i, tt.line, err)
+		}
+	}
+}
+
+func TestLoadCommandLine(t *testing.T) {
+	data := `
+profiles:
+  default:
+    host: localhost
+    tls: true
+    timeout: 5
+    sections:
+      - http2/5
+`
+
+	path := filepath.Join(t.TempDir(), "h2spec.yaml")
+	err := ioutil.WriteFile(path, []byte(data), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := Config{Host: "127.0.0.1", Timeout: 2 * time.Second}
+
+	fs := pflag.NewFlagSet("h2spec", pflag.ContinueOnError)
+	fs.String("config", "", "")
+	fs.String("profile", "", "")
+	BindFlags(fs, &c, []Flag{{Name: "host"}, {Name: "port"}, {Name: "tls"}, {Name: "timeout"}})
+
+	err = fs.Parse([]string{"--config", path, "--timeout", "10"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = LoadCommandLine(&c, fs, []string{"hpack"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Host != "localhost" || !c.TLS || c.Port != 443 {
+		t.Errorf("expect: localhost:443 with TLS, got: %s:%d (TLS: %v)", c.Host, c.Port, c.TLS)
+	}
+	if c.Timeout != 10*time.Second {
+		t.Errorf("timeout - expect: 10s, got: %s", c.Timeout)
+	}
+	if len(c.Sections) != 1 || c.Sections[0] != "hpack" {
+		t.Errorf("sections - expect: [hpack], got: %v", c.Sections)
+	}
+}
+
+func TestForTest(t *testing.T) {
+	c := Config{
+		Path:   "/",
+		Method: "GET",
+		Targets: []Target{
+			{Tests: []string{"generic/4", "http2/6.9"}, Path: "/upload", Method: "POST"},
+			{Tests: []string{"http2/6.9.1"}, Path: "/large"},
+		},
+	}
+
+	tests := []struct {
+		id     string
+		path   string
+		method string
+	}{
+		{id: "generic/4/1", path: "/upload", method: "POST"},
+		{id: "generic/3/1", path: "/", method: "GET"},
+		{id: "generic/40/1", path: "/", method: "GET"},
+		{id: "http2/6.9/1", path: "/upload", method: "POST"},
+		{id: "http2/6.9.1/2", path: "/large", method: "GET"},
+		{id: "http2/6.9.2/1", path: "/upload", method: "POST"},
+	}
+
+	for i, tt := range tests {
+		tc := c.ForTest(tt.id)
+		if tc.Path != tt.path || tc.Method != tt.method {
+			t.Errorf("#%d %s - expect: %s %s, got: %s %s", i, tt.id, tt.method, tt.path, tc.Method, tc.Path)
+		}
+	}
+
+	if c.Path != "/" || c.Method != "GET" {
+		t.Errorf("original config was modified: %s %s", c.Method, c.Path)
+	}
+}
+
+func TestWithTimeout(t *testing.T) {
+	tests := []struct {
+		timeout  time.Duration
+		override time.Duration
+		expected time.Duration
+	}{
+		{timeout: 2 * time.Second, override: 0, expected: 2 * time.Second},
+		{timeout: 2 * time.Second, override: 1 * time.Second, expected: 1 * time.Second},
+		{timeout: 2 * time.Second, override: 5 * time.Second, expected: 5 * time.Second},
+		{timeout: 10 * time.Second, override: 1 * time.Second, expected: 5 * time.Second},
+		{timeout: 10 * time.Second, override: 5 * time.Second, expected: 25 * time.Second},
+		{timeout: 1 * time.Second, override: 5 * time.Second, expected: 2500 * time.Millisecond},
+	}
+
+	for i, tt := range tests {
+		c := Config{Timeout: tt.timeout}
+		tc := c.WithTimeout(tt.override)
+		if tc.Timeout != tt.expected {
+			t.Errorf("#%d - expect: %s, got: %s", i, tt.expected, tc.Timeout)
+		}
+		if c.Timeout != tt.timeout {
+			t.Errorf("#%d - original config was modified: %s", i, c.Timeout)
+		}
+	}
+}
+
+func TestClientSettings(t *testing.T) {
+	c := Config{
+		Settings: []string{"HEADER_TABLE_SIZE=0", "SETTINGS_MAX_FRAME_SIZE=32768"},
+		Targets: []Target{
+			{Tests: []string{"hpack"}, Settings: []string{"header_table_size=256", "ENABLE_PUSH=0"}},
+		},
+	}
+
+	tests := []struct {
+		id       string
+		settings []http2.Setting
+	}{
+		{
+			id: "http2/6.5/1",
+			settings: []http2.Setting{
+				{ID: http2.SettingHeaderTableSize, Val: 0},
+				{ID: http2.SettingMaxFrameSize, Val: 32768},
+			},
+		},
+		{
+			id: "hpack/2.3/1",
+			settings: []http2.Setting{
+				{ID: http2.SettingHeaderTableSize, Val: 256},
+				{ID: http2.SettingMaxFrameSize, Val: 32768},
+				{ID: http2.SettingEnablePush, Val: 0},
+			},
+		},
+	}
+
+	for i, tt := range tests {
+		settings := c.ForTest(tt.id).ClientSettings()
+		if !reflect.DeepEqual(settings, tt.settings) {
+			t.Errorf("#%d %s - expect: %v, got: %v", i, tt.id, tt.settings, settings)
+		}
+	}
+
+	invalid := []string{"HEADER_TABLE_SIZE", "INITIAL_WINDOW_SIZE=0", "ENABLE_PUSH=2", "MAX_FRAME_SIZE=1024", "MAX_HEADER_LIST_SIZE=-1"}
+	for _, setting := range invalid {
+		_, err := ParseSetting(setting)
+		if err == nil {
+			t.Errorf("%s - expect: error, got: nil", setting)
+		}
+	}
+}
+
+func TestShard(t *testing.T) {
+	ids := []string{"generic/1/1", "http2/5.1/3", "http2/6.9.1/2", "hpack/2.3.3/1", "hpack/6.1/1"}
+
+	for _, id := range ids {
+		count := 0
+		for i := 1; i <= 3; i++ {
+			c := Config{Shard: fmt.Sprintf("%d/3", i)}
+			if c.RunMode(id) == RunModeAll {
+				count++
+			}
+
+			// Groups are not assigned to shards.
+			if c.RunMode("http2/5.1") != RunModeAll {
+				t.Errorf("shard %d/3 - expect: group to be run, got: %d", i, c.RunMode("http2/5.1"))
+			}
+		}
+
+		if count != 1 {
+			t.Errorf("%s - expect: 1 shard, got: %d", id, count)
+		}
+	}
+
+	invalid := []string{"0/3", "4/3", "1", "a/3", "1/0"}
+	for _, shard := range invalid {
+		_, _, err := ParseShard(shard)
+		if err == nil {
+			t.Errorf("%s - expect: error, got: nil", shard)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultProfile is the name of the profile used when no profile is
+// specified.
+const DefaultProfile = "default"
+
+// File represents a configuration file that contains named profiles.
+// Each profile uses the same option names as the command line flags.
+type File struct {
+	Profiles map[string]yaml.Node `yaml:"profiles"`
+}
+
+// LoadCommandLine applies the profile of the configuration file
+// specified by the --config and --profile flags to the configuration,
+// which is bound to the flags. Options specified on the command line,
+// and the sections if any are given as arguments, take precedence
+// over the file. The port defaults to 80, or 443 with TLS.
+func LoadCommandLine(c *Config, fs *pflag.FlagSet, sections []string) error {
+	c.Sections = sections
+
+	path, err := fs.GetString("config")
+	if err != nil {
+		return err
+	}
+
+	profile, err := fs.GetString("profile")
+	if err != nil {
+		return err
+	}
+
+	if path != "" {
+		err = LoadProfile(c, path, profile, func(option string) bool {
+			if option == "sections" {
+				return len(sections) > 0
+			}
+			return fs.Changed(option)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.Port == 0 {
+		if c.TLS {
+			c.Port = 443
+		} else {
+			c.Port = 80
+		}
+	}
+
+	return nil
+}
+
+// LoadProfile reads the profile of the specified name from the
+// configuration file and applies its values to the configuration.
+// Options for which override returns true are left untouched so
+// that command line flags take precedence over the file. Durations
+// are specified in seconds as on the command line, or as a duration
+// such as "1.5s".
+func LoadProfile(c *Config, path, name string, override func(option string) bool) error {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var f File
+	err = yaml.Unmarshal(buf, &f)
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+
+	if name == "" {
+		name = DefaultProfile
+	}
+
+	node, ok := f.Profiles[name]
+	if !ok {
+		return fmt.Errorf("%s: profile %q not found (available: %s)", path, name, f.profileNames())
+	}
+
+	// The profile is decoded from its node in the file so that errors
+	// refer to the lines of the file.
+	err = checkOptions(&node, reflect.TypeOf(c))
+	if err != nil {
+		return fmt.Errorf("%s: profile %q: %v", path, name, err)
+	}
+
+	// The values of the options specified on the command line are
+	// restored after decoding the profile.
+	saved := map[string]reflect.Value{}
+	if override != nil && node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			option := node.Content[i].Value
+			if override(option) {
+				v := reflect.ValueOf(c.option(option)).Elem()
+				saved[option] = reflect.ValueOf(v.Interface())
+			}
+		}
+	}
+
+	err = node.Decode(c)
+	if err != nil {
+		return fmt.Errorf("%s: profile %q: %v", path, name, err)
+	}
+
+	for option, v := range saved {
+		reflect.ValueOf(c.option(option)).Elem().Set(v)
+	}
+
+	return nil
+}
+
+// checkOptions returns an error with the line in the file if the node
+// contains an option that is not a field of the type. Durations given
+// as integers are converted to seconds.
+func checkOptions(node *yaml.Node, t reflect.Type) error {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		if t.Kind() != reflect.Struct {
+			return nil
+		}
+
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+
+			field, ok := fieldByOption(t, key.Value)
+			if !ok {
+				return fmt.Errorf("line %d: unknown option %q", key.Line, key.Value)
+			}
+
+			if field.Type == reflect.TypeOf(time.Duration(0)) && value.Kind == yaml.ScalarNode && value.Tag == "!!int" {
+				value.Tag = "!!str"
+				value.Value += "s"
+			}
+
+			err := checkOptions(value, field.Type)
+			if err != nil {
+				return err
+			}
+		}
+	case yaml.SequenceNode:
+		if t.Kind() != reflect.Slice {
+			return nil
+		}
+
+		for _, n := range node.Content {
+			err := checkOptions(n, t.Elem())
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// fieldByOption returns the field of the struct type for the option of
+// the specified name.
+func fieldByOption(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		tag := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+		if tag != "" && tag != "-" && tag == name {
+			return t.Field(i), true
+		}
+	}
+
+	return reflect.StructField{}, false
+}
+
+func (f *File) profileNames() string {
+	var names []string
+	for name := range f.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if len(names) == 0 {
+		return "none"
+	}
+
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// Flag represents a command line flag bound to an option of the
+// configuration. The name of the flag is the name of the option in
+// the configuration file.
+type Flag struct {
+	Name      string
+	Shorthand string
+	Usage     string
+}
+
+// BindFlags defines the flags in the flag set and binds each of them
+// to the field of the option of the same name, so that parsing the
+// flags sets the fields. The current values of the fields are the
+// defaults of the flags. Durations are specified in seconds.
+func BindFlags(fs *pflag.FlagSet, c *Config, flags []Flag) {
+	for _, f := range flags {
+		switch p := c.option(f.Name).(type) {
+		case *string:
+			fs.StringVarP(p, f.Name, f.Shorthand, *p, f.Usage)
+		case *[]string:
+			fs.StringArrayVarP(p, f.Name, f.Shorthand, *p, f.Usage)
+		case *bool:
+			fs.BoolVarP(p, f.Name, f.Shorthand, *p, f.Usage)
+		case *int:
+			fs.IntVarP(p, f.Name, f.Shorthand, *p, f.Usage)
+		case *int64:
+			fs.Int64VarP(p, f.Name, f.Shorthand, *p, f.Usage)
+		case *time.Duration:
+			fs.VarP((*secondsValue)(p), f.Name, f.Shorthand, f.Usage)
+		default:
+			panic(fmt.Sprintf("unsupported option: %s", f.Name))
+		}
+	}
+}
+
+// option returns the pointer to the field of the option of the
+// specified name.
+func (c *Config) option(name string) interface{} {
+	v := reflect.ValueOf(c).Elem()
+
+	field, ok := fieldByOption(v.Type(), name)
+	if !ok {
+		return nil
+	}
+
+	return v.FieldByIndex(field.Index).Addr().Interface()
+}
+
+// secondsValue is the value of a flag that specifies a duration in
+// seconds.
+type secondsValue time.Duration
+
+func (s *secondsValue) String() string {
+	return strconv.Itoa(int(time.Duration(*s) / time.Second))
+}
+
+func (s *secondsValue) Set(value string) error {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return err
+	}
+
+	*s = secondsValue(time.Duration(n) * time.Second)
+	return nil
+}
+
+func (s *secondsValue) Type() string {
+	return "int"
+}
@@ -1,9 +1,12 @@
 package h2spec
 
 import (
+	"errors"
 	"fmt"
+	"math/rand"
 	"time"
 
+	"github.com/summerwind/h2spec/baseline"
 	"github.com/summerwind/h2spec/client"
 	"github.com/summerwind/h2spec/config"
 	"github.com/summerwind/h2spec/generic"
@@ -12,22 +15,176 @@ import (
 	"github.com/summerwind/h2spec/log"
 	"github.com/summerwind/h2spec/reporter"
 	"github.com/summerwind/h2spec/spec"
+	"github.com/summerwind/h2spec/stress"
+	"github.com/summerwind/h2spec/tls"
 )
 
+// Run runs the test cases selected by the configuration and returns
+// false if any of them failed. It also returns false with any error,
+// such as an invalid configuration, an aborted preflight check or a
+// report that could not be read or written.
 func Run(c *config.Config) (bool, error) {
-	total := 0
 	success := true
 
-	specs := []*spec.TestGroup{
-		generic.Spec(),
-		http2.Spec(),
-		hpack.Spec(),
+	err := c.Validate()
+	if err != nil {
+		return false, err
+	}
+
+	err = c.LoadBody()
+	if err != nil {
+		return false, err
+	}
+
+	total := 0
+	flaky := 0
+	notRun := 0
+
+	// Stress tests are only run on demand, and never against remote
+	// servers since they are indistinguishable from an attack.
+	if c.Stress && !c.DryRun && !c.IsLocalTarget() {
+		return false, errors.New("Stress tests can only be run against a local server")
+	}
+
+	specs := newSpecs(c)
+
+	// Only the test cases that failed in the previous run and are
+	// included in the sections specified are run.
+	if c.RerunFailed != "" {
+		failed, err := reporter.LoadFailedTests(c.RerunFailed)
+		if err != nil {
+			return false, err
+		}
+
+		ids := failedTestIDs(specs, failed)
+		if len(ids) == 0 {
+			log.SetIndentLevel(0)
+			log.Println(fmt.Sprintf("No failed tests found in %s.", c.RerunFailed))
+			return true, nil
+		}
+
+		var selected []string
+		for _, id := range ids {
+			if c.RunMode(id) == config.RunModeAll {
+				selected = append(selected, id)
+			}
+		}
+		if len(selected) == 0 {
+			log.SetIndentLevel(0)
+			log.Println(fmt.Sprintf("No failed tests in %s match the specified sections.", c.RerunFailed))
+			return true, nil
+		}
+
+		c.SetSections(selected...)
+	}
+
+	var b *baseline.Baseline
+	if c.Baseline != "" {
+		var err error
+		b, err = baseline.Load(c.Baseline)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	var prev *reporter.JSONTestReport
+	if c.Compare != "" {
+		var err error
+		prev, err = reporter.LoadJSONReport(c.Compare)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	// The preflight checks run before the TLS parameters are shown
+	// so that a failed negotiation is diagnosed.
+	preflight := c.Preflight != "" && c.Preflight != config.PreflightOff
+	if preflight && !c.DryRun {
+		checks := spec.Preflight(c)
+		reporter.Preflight(checks)
+
+		for _, check := range checks {
+			if check.Failed && check.Fatal && c.Preflight == config.PreflightAbort {
+				return false, errors.New("Preflight checks failed")
+			}
+		}
+	}
+
+	// The negotiated TLS parameters are shown in the header of the
+	// run since they may differ from the expected ones.
+	var tlsInfo *spec.TLSInfo
+	if c.TLS && !c.DryRun {
+		tlsInfo, err = spec.NegotiateTLS(c)
+		if err != nil {
+			return false, err
+		}
+
+		log.SetIndentLevel(0)
+		log.Println(fmt.Sprintf("TLS: %s, %s, ALPN: %s", tlsInfo.Version, tlsInfo.CipherSuite, tlsInfo.ALPN))
+		log.PrintBlankLine()
+	}
+
+	// Running the test suite in several orders implies shuffling.
+	// Each order uses the seed following the one of the previous
+	// order.
+	orders := 1
+	if c.Orders > 1 {
+		orders = c.Orders
+	}
+
+	shuffle := c.Shuffle || orders > 1
+	seed := c.Seed
+	if shuffle && seed == 0 {
+		seed = time.Now().UnixNano()
 	}
 
 	start := time.Now()
-	for _, s := range specs {
-		s.Test(c)
+	if c.MaxDuration > 0 {
+		c.Deadline = start.Add(c.MaxDuration)
+	}
+
+	var seeds []int64
+	var runs [][]*spec.TestResult
+	var runErr error
+
+	for i := 0; i < orders; i++ {
+		s := specs
+		if i > 0 {
+			s = newSpecs(c)
+		}
+
+		var r *rand.Rand
+		if shuffle {
+			orderSeed := seed + int64(i)
+			seeds = append(seeds, orderSeed)
+			r = rand.New(rand.NewSource(orderSeed))
+
+			log.SetIndentLevel(0)
+			if orders > 1 {
+				log.Println(fmt.Sprintf("Order %d of %d (seed: %d)", i+1, orders, orderSeed))
+			} else {
+				log.Println(fmt.Sprintf("Shuffle seed: %d", orderSeed))
+			}
+			log.PrintBlankLine()
+		}
+
+		runErr = runSpecs(c, s, r)
 
+		var results []*spec.TestResult
+		for _, tg := range s {
+			results = append(results, tg.TestResults()...)
+		}
+		runs = append(runs, results)
+
+		// The reports are written with the results of the test cases
+		// that were run before the error.
+		if runErr != nil {
+			break
+		}
+	}
+
+	// The results of the first order are reported.
+	for _, s := range specs {
 		if s.FailedCount > 0 {
 			success = false
 		}
@@ -35,6 +192,8 @@ func Run(c *config.Config) (bool, error) {
 		total += s.FailedCount
 		total += s.SkippedCount
 		total += s.PassedCount
+		flaky += s.FlakyCount
+		notRun += s.NotRunCount
 	}
 	end := time.Now()
 	d := end.Sub(start)
@@ -43,7 +202,7 @@ func Run(c *config.Config) (bool, error) {
 		return true, nil
 	}
 
-	if total == 0 {
+	if total == 0 && notRun == 0 {
 		log.SetIndentLevel(0)
 		log.Println("No matched tests found.")
 		return true, nil
@@ -54,23 +213,171 @@ func Run(c *config.Config) (bool, error) {
 		reporter.FailedTests(specs)
 	}
 
+	if flaky > 0 {
+		log.SetIndentLevel(0)
+		reporter.FlakyTests(specs)
+	}
+
+	var results []*spec.TestResult
+	for _, s := range specs {
+		results = append(results, s.TestResults()...)
+	}
+
+	// With a baseline, only failures that are not in the baseline
+	// make the run fail.
+	if b != nil {
+		cmp := b.Compare(results)
+		reporter.BaselineComparison(b, cmp)
+		success = len(cmp.NewFailures) == 0
+	}
+
+	// A run that could not be completed within the maximum duration
+	// or that was stopped by an error is not successful.
+	if notRun > 0 {
+		success = false
+	}
+
 	log.SetIndentLevel(0)
 	log.Println(fmt.Sprintf("Finished in %.4f seconds", d.Seconds()))
 	reporter.Summary(specs)
 
+	if c.Timing {
+		log.PrintBlankLine()
+		reporter.Timing(specs, c)
+	}
+
+	if orders > 1 {
+		log.PrintBlankLine()
+		deps := reporter.CompareOrders(runs)
+		reporter.OrderDependences(seeds, deps)
+		if len(deps) > 0 {
+			success = false
+		}
+	}
+
+	if prev != nil {
+		log.PrintBlankLine()
+		reporter.PrintDiff(reporter.DiffJSONReports(prev, reporter.NewJSONReport(specs, d)))
+	}
+
 	if c.JUnitReport != "" {
-		err := reporter.JUnitReport(specs, c.JUnitReport)
+		err := reporter.JUnitReport(specs, tlsInfo, c.JUnitReport)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if c.JSONReport != "" {
+		err := reporter.JSONReport(specs, d, tlsInfo, c.JSONReport)
 		if err != nil {
 			return false, err
 		}
 	}
 
+	if c.GenBaseline != "" {
+		err := baseline.Write(results, c.GenBaseline)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if runErr != nil {
+		return false, runErr
+	}
+
 	return success, nil
 }
 
+// newSpecs returns the test groups to run based on the configuration.
+func newSpecs(c *config.Config) []*spec.TestGroup {
+	specs := []*spec.TestGroup{
+		generic.Spec(),
+		http2.Spec(),
+		hpack.Spec(),
+	}
+
+	if c.TLS {
+		specs = append(specs, tls.Spec())
+	}
+
+	if c.Stress {
+		specs = append(specs, stress.Spec())
+	}
+
+	return specs
+}
+
+// failedTestIDs returns the IDs of the failed test cases. Test cases
+// without ID are looked up by their group and description, and are
+// ignored if they no longer exist.
+func failedTestIDs(specs []*spec.TestGroup, failed []*reporter.FailedTest) []string {
+	var ids []string
+
+	for _, ft := range failed {
+		if ft.ID != "" {
+			ids = append(ids, ft.ID)
+			continue
+		}
+
+		for _, s := range specs {
+			id, ok := s.FindTestCase(ft.Group, ft.Desc)
+			if ok {
+				ids = append(ids, id)
+				break
+			}
+		}
+	}
+
+	return ids
+}
+
+// runSpecs runs the test groups. If r is not nil, the order of the
+// test groups and their test cases is randomized with r. It returns
+// the error that stopped the run, if any. The test cases after it are
+// reported as not run.
+func runSpecs(c *config.Config, specs []*spec.TestGroup, r *rand.Rand) error {
+	order := make([]int, len(specs))
+	for i := range order {
+		order[i] = i
+	}
+
+	if r != nil {
+		order = r.Perm(len(specs))
+		for _, s := range specs {
+			s.Shuffle(r)
+		}
+	}
+
+	state := &spec.RunState{}
+	for _, i := range order {
+		specs[i].Test(c, state)
+	}
+
+	return state.Err
+}
+
 func RunClientSpec(c *config.Config) error {
+	err := c.Validate()
+	if err != nil {
+		return err
+	}
+
+	err = c.LoadBody()
+	if err != nil {
+		return err
+	}
+
 	s := client.Spec()
 
+	var prev *reporter.JSONTestReport
+	if c.Compare != "" {
+		var err error
+		prev, err = reporter.LoadJSONReport(c.Compare)
+		if err != nil {
+			return err
+		}
+	}
+
 	server, err := spec.Listen(c, s)
 	if err != nil {
 		return err
@@ -90,6 +397,18 @@ func RunClientSpec(c *config.Config) error {
 		log.SetIndentLevel(0)
 		log.Println(fmt.Sprintf("Finished in %.4f seconds", d.Seconds()))
 		reporter.PrintSummaryForClient(s)
+
+		if prev != nil {
+			log.PrintBlankLine()
+			reporter.PrintDiff(reporter.DiffJSONReports(prev, reporter.NewClientJSONReport(s, d)))
+		}
+
+		if c.JSONReport != "" {
+			err := reporter.ClientJSONReport(s, d, c.JSONReport)
+			if err != nil {
+				return err
+			}
+		}
 	} else {
 		// Block running
 		log.Println("--exec is not defined, enable BROWSER mode")
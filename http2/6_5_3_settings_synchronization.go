@@ -51,14 +51,7 @@ func SettingsSynchronization() *spec.TestGroup {
 				return err
 			}
 
-			headers := spec.CommonHeaders(c)
-			hp := http2.HeadersFrameParam{
-				StreamID:      streamID,
-				EndStream:     true,
-				EndHeaders:    true,
-				BlockFragment: conn.EncodeHeaders(headers),
-			}
-			conn.WriteHeaders(hp)
+			spec.WriteRequest(c, conn, streamID)
 
 			actual, passed := conn.WaitEventByType(spec.EventDataFrame)
 			switch event := actual.(type) {
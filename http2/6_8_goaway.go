@@ -1,12 +1,20 @@
 package http2
 
 import (
+	"fmt"
+	"time"
+
 	"golang.org/x/net/http2"
 
 	"github.com/summerwind/h2spec/config"
 	"github.com/summerwind/h2spec/spec"
 )
 
+// maxStreamID is the largest stream identifier. It is used as the
+// last stream identifier of the first GOAWAY frame of a graceful
+// shutdown.
+const maxStreamID = 0x7fffffff
+
 func GoAway() *spec.TestGroup {
 	tg := NewTestGroup("6.8", "GOAWAY")
 
@@ -31,5 +39,296 @@ func GoAway() *spec.TestGroup {
 		},
 	})
 
+	// GOAWAY allows an endpoint to gracefully stop accepting new
+	// streams while still finishing processing of previously
+	// established streams.
+	tg.AddTestCase(&spec.TestCase{
+		Desc:        "Sends a GOAWAY frame after sending a request",
+		Requirement: "The endpoint MUST finish processing of previously established streams.",
+		Run: func(c *config.Config, conn *spec.Conn) error {
+			var streamID uint32 = 1
+
+			err := conn.Handshake()
+			if err != nil {
+				return err
+			}
+
+			headers := spec.CommonHeaders(c)
+			hp := http2.HeadersFrameParam{
+				StreamID:      streamID,
+				EndStream:     true,
+				EndHeaders:    true,
+				BlockFragment: conn.EncodeHeaders(headers),
+			}
+			conn.WriteHeaders(hp)
+
+			conn.WriteGoAway(0, http2.ErrCodeNo, []byte("h2spec"))
+
+			return spec.VerifyStreamClose(conn)
+		},
+	})
+
+	// Receivers of a GOAWAY frame MUST NOT open additional streams
+	// on the connection, although a new connection can be established
+	// for new streams.
+	tg.AddTestCase(&spec.TestCase{
+		Desc:        "Sends a GOAWAY frame before completing a request",
+		Requirement: "The endpoint MUST NOT open additional streams by sending a PUSH_PROMISE frame.",
+		Run: func(c *config.Config, conn *spec.Conn) error {
+			var streamID uint32 = 1
+			var actual spec.Event
+
+			err := conn.Handshake()
+			if err != nil {
+				return err
+			}
+
+			headers := spec.CommonHeaders(c)
+			hp := http2.HeadersFrameParam{
+				StreamID:      streamID,
+				EndStream:     false,
+				EndHeaders:    true,
+				BlockFragment: conn.EncodeHeaders(headers),
+			}
+			conn.WriteHeaders(hp)
+
+			conn.WriteGoAway(0, http2.ErrCodeNo, []byte("h2spec"))
+			conn.WriteData(streamID, true, []byte{})
+
+			passed := false
+			for !conn.Closed {
+				ev := conn.WaitEvent()
+
+				switch event := ev.(type) {
+				case spec.PushPromiseFrameEvent:
+					actual = event
+				case spec.DataFrameEvent:
+					passed = event.StreamEnded()
+				case spec.HeadersFrameEvent:
+					passed = event.StreamEnded()
+				case spec.TimeoutEvent:
+					if actual == nil {
+						actual = event
+					}
+				default:
+					actual = event
+				}
+
+				if passed || ev.Type() == spec.EventPushPromiseFrame {
+					break
+				}
+			}
+
+			if !passed {
+				return &spec.TestError{
+					Expected: []string{spec.ExpectedStreamClosed},
+					Actual:   actual.String(),
+				}
+			}
+
+			return nil
+		},
+	})
+
+	// The last stream identifier in the GOAWAY frame contains the
+	// highest-numbered stream identifier for which the sender of the
+	// GOAWAY frame might have taken some action on or might yet take
+	// action on.
+	tg.AddTestCase(&spec.TestCase{
+		Desc:        "Sends an invalid PING frame after a request has been processed",
+		Requirement: "The endpoint MUST send a GOAWAY frame with the last stream identifier of the processed stream.",
+		Run: func(c *config.Config, conn *spec.Conn) error {
+			var streamID uint32 = 1
+
+			err := conn.Handshake()
+			if err != nil {
+				return err
+			}
+
+			headers := spec.CommonHeaders(c)
+			hp := http2.HeadersFrameParam{
+				StreamID:      streamID,
+				EndStream:     true,
+				EndHeaders:    true,
+				BlockFragment: conn.EncodeHeaders(headers),
+			}
+			conn.WriteHeaders(hp)
+
+			err = spec.VerifyStreamClose(conn)
+			if err != nil {
+				return err
+			}
+
+			// PING frame with invalid stream ID
+			conn.Send([]byte("\x00\x00\x08\x06\x00\x00\x00\x00\x03"))
+			conn.Send([]byte("\x00\x00\x00\x00\x00\x00\x00\x00"))
+
+			return verifyGoAwayLastStreamID(conn, streamID)
+		},
+	})
+
+	// Once sent, the sender will ignore frames sent on streams
+	// initiated by the receiver if the stream has an identifier
+	// higher than the included last stream identifier.
+	tg.AddTestCase(&spec.TestCase{
+		Desc:        "Sends a request on a stream above the last stream identifier of a graceful shutdown",
+		Requirement: "The endpoint MUST NOT process the stream.",
+		// The test case passes when no response is received until
+		// the timeout.
+		Timeout: 1 * time.Second,
+		Run: func(c *config.Config, conn *spec.Conn) error {
+			var streamID uint32 = 1
+			var actual spec.Event
+
+			// Skip this test case when no path to trigger a
+			// graceful shutdown is given.
+			if c.ShutdownPath == "" {
+				return spec.ErrSkipped
+			}
+
+			err := conn.Handshake()
+			if err != nil {
+				return err
+			}
+
+			headers := spec.CommonHeaders(c)
+			for i, hf := range headers {
+				if hf.Name == ":path" {
+					headers[i].Value = c.ShutdownPath
+				}
+			}
+
+			hp := http2.HeadersFrameParam{
+				StreamID:      streamID,
+				EndStream:     true,
+				EndHeaders:    true,
+				BlockFragment: conn.EncodeHeaders(headers),
+			}
+			conn.WriteHeaders(hp)
+
+			// A graceful shutdown may start with a GOAWAY frame that
+			// contains the maximum stream identifier, so we wait for
+			// the GOAWAY frame with the actual last stream identifier.
+			var goAway *spec.GoAwayFrameEvent
+			for !conn.Closed {
+				ev := conn.WaitEvent()
+
+				event, ok := ev.(spec.GoAwayFrameEvent)
+				if ok {
+					if event.LastStreamID != maxStreamID {
+						goAway = &event
+						break
+					}
+					continue
+				}
+
+				if ev.Type() != spec.EventTimeout || actual == nil {
+					actual = ev
+				}
+			}
+
+			if goAway == nil {
+				return &spec.TestError{
+					Expected: []string{fmt.Sprintf(spec.ExpectedGoAwayFrame, http2.ErrCodeNo)},
+					Actual:   actual.String(),
+				}
+			}
+
+			if goAway.LastStreamID < streamID {
+				return &spec.TestError{
+					Expected: []string{
+						fmt.Sprintf("GOAWAY Frame (last_stream_id:%d)", streamID),
+					},
+					Actual: fmt.Sprintf("GOAWAY Frame (last_stream_id:%d)", goAway.LastStreamID),
+				}
+			}
+
+			nextStreamID := goAway.LastStreamID + 2
+			if nextStreamID%2 == 0 {
+				nextStreamID -= 1
+			}
+
+			headers = spec.CommonHeaders(c)
+			hp = http2.HeadersFrameParam{
+				StreamID:      nextStreamID,
+				EndStream:     true,
+				EndHeaders:    true,
+				BlockFragment: conn.EncodeHeaders(headers),
+			}
+			conn.WriteHeaders(hp)
+
+			for !conn.Closed {
+				ev := conn.WaitEvent()
+
+				event, ok := ev.(spec.HeadersFrameEvent)
+				if ok && event.Header().StreamID == nextStreamID {
+					return &spec.TestError{
+						Expected: []string{
+							fmt.Sprintf(spec.ExpectedRSTStreamFrame, http2.ErrCodeRefusedStream),
+							spec.ExpectedConnectionClosed,
+						},
+						Actual: event.String(),
+					}
+				}
+			}
+
+			return nil
+		},
+	})
+
 	return tg
 }
+
+// verifyGoAwayLastStreamID verifies whether a GOAWAY frame with the
+// specified last stream identifier has received. An endpoint may
+// close the connection without sending GOAWAY frame, so that is
+// also treated as success.
+func verifyGoAwayLastStreamID(conn *spec.Conn, lastStreamID uint32) error {
+	var actual spec.Event
+
+	passed := false
+	for !conn.Closed {
+		ev := conn.WaitEvent()
+
+		switch event := ev.(type) {
+		case spec.ConnectionClosedEvent:
+			passed = true
+		case spec.GoAwayFrameEvent:
+			passed = (event.LastStreamID == lastStreamID)
+			if !passed {
+				actual = event
+			}
+		case spec.TimeoutEvent:
+			if actual == nil {
+				actual = event
+			}
+		default:
+			actual = event
+		}
+
+		if passed || ev.Type() == spec.EventGoAwayFrame {
+			break
+		}
+	}
+
+	if !passed {
+		var actualStr string
+
+		f, ok := actual.(spec.GoAwayFrameEvent)
+		if ok {
+			actualStr = fmt.Sprintf("GOAWAY Frame (last_stream_id:%d, error_code:%s)", f.LastStreamID, f.ErrCode)
+		} else {
+			actualStr = actual.String()
+		}
+
+		return &spec.TestError{
+			Expected: []string{
+				fmt.Sprintf("GOAWAY Frame (last_stream_id:%d)", lastStreamID),
+				spec.ExpectedConnectionClosed,
+			},
+			Actual: actualStr,
+		}
+	}
+
+	return nil
+}
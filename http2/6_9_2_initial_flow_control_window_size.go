@@ -53,14 +53,7 @@ func InitialFlowControlWindowSize() *spec.TestGroup {
 			}
 
 			// Send a HEADERS frame.
-			headers := spec.CommonHeaders(c)
-			hp := http2.HeadersFrameParam{
-				StreamID:      streamID,
-				EndStream:     true,
-				EndHeaders:    true,
-				BlockFragment: conn.EncodeHeaders(headers),
-			}
-			conn.WriteHeaders(hp)
+			spec.WriteRequest(c, conn, streamID)
 
 			// Set SETTINGS_INITIAL_WINDOW_SIZE to 1 so that the server
 			// can send DATA frame.
@@ -137,14 +130,7 @@ func InitialFlowControlWindowSize() *spec.TestGroup {
 			}
 
 			// Send a HEADERS frame.
-			headers := spec.CommonHeaders(c)
-			hp := http2.HeadersFrameParam{
-				StreamID:      streamID,
-				EndStream:     true,
-				EndHeaders:    true,
-				BlockFragment: conn.EncodeHeaders(headers),
-			}
-			conn.WriteHeaders(hp)
+			spec.WriteRequest(c, conn, streamID)
 
 			// Verify reception of DATA frame.
 			err = spec.VerifyEventType(conn, spec.EventDataFrame)
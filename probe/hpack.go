@@ -0,0 +1,133 @@
+package probe
+
+import (
+	"errors"
+)
+
+// staticTableLen is the number of entries of the HPACK static table.
+const staticTableLen = 61
+
+var errInvalidHeaderBlock = errors.New("Invalid header block")
+
+// analyzeHeaderBlock walks through the representations of the header
+// block and counts the use of Huffman coding and the dynamic table
+// defined in RFC 7541 section 6.
+func analyzeHeaderBlock(block []byte, h *HPACK) error {
+	for len(block) > 0 {
+		b := block[0]
+
+		var err error
+		switch {
+		case b&0x80 != 0:
+			// Indexed Header Field Representation
+			var index uint64
+			index, block, err = readInt(block, 7)
+			if err != nil {
+				return err
+			}
+			if index > staticTableLen {
+				h.DynamicRefs++
+			}
+
+		case b&0xc0 == 0x40:
+			// Literal Header Field with Incremental Indexing
+			h.DynamicInserts++
+			block, err = readLiteral(block, 6, h)
+			if err != nil {
+				return err
+			}
+
+		case b&0xe0 == 0x20:
+			// Dynamic Table Size Update
+			h.TableSizeUpdates++
+			_, block, err = readInt(block, 5)
+			if err != nil {
+				return err
+			}
+
+		default:
+			// Literal Header Field without Indexing or Never Indexed
+			block, err = readLiteral(block, 4, h)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// readLiteral reads a literal header field representation whose name
+// index has a prefix of n bits.
+func readLiteral(block []byte, n uint, h *HPACK) ([]byte, error) {
+	index, block, err := readInt(block, n)
+	if err != nil {
+		return nil, err
+	}
+
+	if index == 0 {
+		block, err = readString(block, h)
+		if err != nil {
+			return nil, err
+		}
+	} else if index > staticTableLen {
+		h.DynamicRefs++
+	}
+
+	return readString(block, h)
+}
+
+// readString skips a string literal and counts whether it is encoded
+// with Huffman coding.
+func readString(block []byte, h *HPACK) ([]byte, error) {
+	if len(block) == 0 {
+		return nil, errInvalidHeaderBlock
+	}
+
+	huffman := block[0]&0x80 != 0
+
+	length, block, err := readInt(block, 7)
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(block)) < length {
+		return nil, errInvalidHeaderBlock
+	}
+
+	if huffman {
+		h.HuffmanStrings++
+	} else {
+		h.LiteralStrings++
+	}
+
+	return block[length:], nil
+}
+
+// readInt reads an integer with a prefix of n bits defined in RFC 7541
+// section 5.1.
+func readInt(block []byte, n uint) (uint64, []byte, error) {
+	if len(block) == 0 {
+		return 0, nil, errInvalidHeaderBlock
+	}
+
+	mask := uint64(1)<<n - 1
+	v := uint64(block[0]) & mask
+	if v < mask {
+		return v, block[1:], nil
+	}
+
+	var m uint
+	for i := 1; i < len(block); i++ {
+		b := block[i]
+		v += uint64(b&0x7f) << m
+		m += 7
+		if m > 63 {
+			return 0, nil, errInvalidHeaderBlock
+		}
+		if b&0x80 == 0 {
+			return v, block[i+1:], nil
+		}
+	}
+
+	return 0, nil, errInvalidHeaderBlock
+}
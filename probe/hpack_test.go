@@ -0,0 +1,61 @@
+package probe
+
+import (
+	"bytes"
+	"testing"
+
+	"golang.org/x/net/http2/hpack"
+)
+
+func TestAnalyzeHeaderBlock(t *testing.T) {
+	var buf bytes.Buffer
+	enc := hpack.NewEncoder(&buf)
+
+	fields := []hpack.HeaderField{
+		{Name: ":status", Value: "200"},
+		{Name: "content-type", Value: "text/plain"},
+		{Name: "x-custom", Value: "value"},
+		{Name: "x-secret", Value: "value", Sensitive: true},
+	}
+
+	// The second block references the fields inserted by the first.
+	var blocks [][]byte
+	for i := 0; i < 2; i++ {
+		buf.Reset()
+		for _, f := range fields {
+			enc.WriteField(f)
+		}
+		blocks = append(blocks, append([]byte{}, buf.Bytes()...))
+	}
+
+	h := &HPACK{}
+	err := analyzeHeaderBlock(blocks[0], h)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// content-type and x-custom are inserted, x-secret is never
+	// indexed.
+	if h.DynamicInserts != 2 || h.DynamicRefs != 0 {
+		t.Errorf("first block - expect: 2 inserts and 0 references, got: %d and %d", h.DynamicInserts, h.DynamicRefs)
+	}
+	if !h.UsesHuffman() {
+		t.Errorf("first block - expect: Huffman coding, got: %d Huffman strings", h.HuffmanStrings)
+	}
+
+	h = &HPACK{}
+	err = analyzeHeaderBlock(blocks[1], h)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// x-secret is never indexed, so it is sent as a literal again.
+	if h.DynamicInserts != 0 || h.DynamicRefs != 2 {
+		t.Errorf("second block - expect: 0 inserts and 2 references, got: %d and %d", h.DynamicInserts, h.DynamicRefs)
+	}
+
+	err = analyzeHeaderBlock([]byte{0x40, 0x05, 'a'}, &HPACK{})
+	if err != errInvalidHeaderBlock {
+		t.Errorf("truncated block - expect: %v, got: %v", errInvalidHeaderBlock, err)
+	}
+}
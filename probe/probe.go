@@ -0,0 +1,455 @@
+package probe
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"golang.org/x/net/http2"
+
+	"github.com/summerwind/h2spec/config"
+	"github.com/summerwind/h2spec/spec"
+)
+
+// pingCount is the number of PING frames sent to measure the round
+// trip time.
+const pingCount = 3
+
+// Report represents the capabilities and the behaviour of the server
+// observed by the probe. A section is nil if it could not be probed,
+// the reason is recorded in Errors.
+type Report struct {
+	Target string   `json:"target"`
+	TLS    *TLSInfo `json:"tls,omitempty"`
+
+	Settings             []*Setting `json:"settings"`
+	HeaderTableSize      uint32     `json:"header_table_size"`
+	MaxConcurrentStreams *uint32    `json:"max_concurrent_streams"`
+	InitialWindowSize    uint32     `json:"initial_window_size"`
+	MaxFrameSize         uint32     `json:"max_frame_size"`
+	MaxHeaderListSize    *uint32    `json:"max_header_list_size"`
+
+	Push         *bool         `json:"push,omitempty"`
+	HPACK        *HPACK        `json:"hpack,omitempty"`
+	PingRTT      *float64      `json:"ping_rtt,omitempty"`
+	WindowUpdate *WindowUpdate `json:"window_update,omitempty"`
+	GoAway       *GoAway       `json:"goaway,omitempty"`
+
+	Errors []string `json:"errors,omitempty"`
+}
+
+// TLSInfo represents the parameters negotiated in the TLS handshake.
+type TLSInfo struct {
+	Version     string `json:"version"`
+	CipherSuite string `json:"cipher_suite"`
+	ALPN        string `json:"alpn"`
+}
+
+// Setting represents a parameter of the SETTINGS frame sent by the
+// server.
+type Setting struct {
+	ID    uint16 `json:"id"`
+	Name  string `json:"name"`
+	Value uint32 `json:"value"`
+}
+
+// HPACK represents how the server encodes the header fields of the
+// responses to two identical requests.
+type HPACK struct {
+	HuffmanStrings   int `json:"huffman_strings"`
+	LiteralStrings   int `json:"literal_strings"`
+	DynamicInserts   int `json:"dynamic_table_inserts"`
+	DynamicRefs      int `json:"dynamic_table_references"`
+	TableSizeUpdates int `json:"table_size_updates"`
+}
+
+// UsesHuffman returns true if the server encoded any string with
+// Huffman coding.
+func (h *HPACK) UsesHuffman() bool {
+	return h.HuffmanStrings > 0
+}
+
+// UsesDynamicTable returns true if the server inserted header fields
+// into the dynamic table or referenced them.
+func (h *HPACK) UsesDynamicTable() bool {
+	return h.DynamicInserts > 0 || h.DynamicRefs > 0
+}
+
+// WindowUpdate represents the WINDOW_UPDATE frames sent by the server
+// while receiving a request body.
+type WindowUpdate struct {
+	BodyLength          int    `json:"body_length"`
+	DataFrames          int    `json:"data_frames"`
+	ConnectionUpdates   int    `json:"connection_updates"`
+	ConnectionIncrement int    `json:"connection_increment"`
+	StreamUpdates       int    `json:"stream_updates"`
+	StreamIncrement     int    `json:"stream_increment"`
+	Strategy            string `json:"strategy"`
+}
+
+// GoAway represents the behaviour of the server when the client sends
+// a GOAWAY frame while a request is in flight.
+type GoAway struct {
+	ResponseCompleted bool   `json:"response_completed"`
+	GoAwaySent        bool   `json:"goaway_sent"`
+	ErrorCode         string `json:"error_code,omitempty"`
+	ConnectionClosed  bool   `json:"connection_closed"`
+}
+
+// Run probes the server of the configuration. Errors of individual
+// probes are recorded in the report, an error is only returned if no
+// connection can be established.
+func Run(c *config.Config) (*Report, error) {
+	conn, err := spec.Dial(c)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	err = conn.Handshake()
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Report{Target: c.Addr()}
+
+	info := conn.TLSInfo()
+	if info != nil {
+		r.TLS = &TLSInfo{
+			Version:     info.Version,
+			CipherSuite: info.CipherSuite,
+			ALPN:        info.ALPN,
+		}
+	}
+
+	r.setSettings(conn.Settings)
+
+	err = r.probeResponses(c, conn)
+	if err != nil {
+		r.addError("responses", err)
+	}
+
+	rtt, err := pingRTT(conn)
+	if err != nil {
+		r.addError("ping", err)
+	} else {
+		r.PingRTT = &rtt
+	}
+
+	r.WindowUpdate, err = probeWindowUpdate(c)
+	if err != nil {
+		r.addError("window update", err)
+	}
+
+	r.GoAway, err = probeGoAway(c)
+	if err != nil {
+		r.addError("goaway", err)
+	}
+
+	return r, nil
+}
+
+func (r *Report) addError(probe string, err error) {
+	r.Errors = append(r.Errors, fmt.Sprintf("%s: %v", probe, err))
+}
+
+// setSettings records the settings with the initial values of the
+// settings that the server did not send.
+func (r *Report) setSettings(settings map[http2.SettingID]uint32) {
+	for id, val := range settings {
+		r.Settings = append(r.Settings, &Setting{
+			ID:    uint16(id),
+			Name:  id.String(),
+			Value: val,
+		})
+	}
+	sort.Slice(r.Settings, func(i, j int) bool {
+		return r.Settings[i].ID < r.Settings[j].ID
+	})
+
+	value := func(id http2.SettingID, initial uint32) uint32 {
+		val, ok := settings[id]
+		if !ok {
+			return initial
+		}
+		return val
+	}
+
+	r.HeaderTableSize = value(http2.SettingHeaderTableSize, 4096)
+	r.InitialWindowSize = value(http2.SettingInitialWindowSize, spec.DefaultWindowSize)
+	r.MaxFrameSize = value(http2.SettingMaxFrameSize, spec.DefaultFrameSize)
+
+	// These settings are unlimited unless they are sent.
+	if val, ok := settings[http2.SettingMaxConcurrentStreams]; ok {
+		r.MaxConcurrentStreams = &val
+	}
+	if val, ok := settings[http2.SettingMaxHeaderListSize]; ok {
+		r.MaxHeaderListSize = &val
+	}
+}
+
+// probeResponses sends the same request twice and records whether
+// the server pushed and how it encoded the header fields.
+func (r *Report) probeResponses(c *config.Config, conn *spec.Conn) error {
+	push := false
+	h := &HPACK{}
+
+	for _, streamID := range []uint32{1, 3} {
+		hp := http2.HeadersFrameParam{
+			StreamID:      streamID,
+			EndStream:     true,
+			EndHeaders:    true,
+			BlockFragment: conn.EncodeHeaders(spec.CommonHeaders(c)),
+		}
+		err := conn.WriteHeaders(hp)
+		if err != nil {
+			return err
+		}
+
+		var block []byte
+		done := false
+		for !done {
+			ev := conn.WaitEvent()
+
+			switch event := ev.(type) {
+			case spec.PushPromiseFrameEvent:
+				push = true
+			case spec.HeadersFrameEvent:
+				if event.Header().StreamID != streamID {
+					continue
+				}
+				block = append(block, event.HeaderBlockFragment()...)
+				if event.HeadersEnded() {
+					err = analyzeHeaderBlock(block, h)
+					if err != nil {
+						return err
+					}
+					block = nil
+				}
+				done = event.StreamEnded()
+			case spec.ContinuationFrameEvent:
+				if event.Header().StreamID != streamID {
+					continue
+				}
+				block = append(block, event.HeaderBlockFragment()...)
+				if event.HeadersEnded() {
+					err = analyzeHeaderBlock(block, h)
+					if err != nil {
+						return err
+					}
+					block = nil
+				}
+			case spec.DataFrameEvent:
+				done = event.Header().StreamID == streamID && event.StreamEnded()
+			case spec.RSTStreamFrameEvent:
+				done = event.Header().StreamID == streamID
+			case spec.GoAwayFrameEvent, spec.ConnectionClosedEvent, spec.ErrorEvent, spec.TimeoutEvent:
+				return errors.New(ev.String())
+			}
+		}
+	}
+
+	r.Push = &push
+	r.HPACK = h
+
+	return nil
+}
+
+// pingRTT returns the average round trip time of PING frames in
+// seconds.
+func pingRTT(conn *spec.Conn) (float64, error) {
+	var total time.Duration
+
+	for i := 0; i < pingCount; i++ {
+		data := [8]byte{'h', '2', 's', 'p', 'e', 'c', 0, byte(i)}
+
+		start := time.Now()
+		err := ping(conn, data)
+		if err != nil {
+			return 0, err
+		}
+
+		total += time.Since(start)
+	}
+
+	return (total / pingCount).Seconds(), nil
+}
+
+// ping sends a PING frame with the data and waits for its ACK. Other
+// frames received before the ACK are discarded.
+func ping(conn *spec.Conn, data [8]byte) error {
+	err := conn.WritePing(false, data)
+	if err != nil {
+		return err
+	}
+
+	for {
+		ev := conn.WaitEvent()
+
+		switch event := ev.(type) {
+		case spec.PingFrameEvent:
+			if event.IsAck() && event.Data == data {
+				return nil
+			}
+		case spec.GoAwayFrameEvent, spec.ConnectionClosedEvent, spec.ErrorEvent, spec.TimeoutEvent:
+			return errors.New(ev.String())
+		}
+	}
+}
+
+// probeWindowUpdate sends a request body that fills the initial flow
+// control window of the stream and records the WINDOW_UPDATE frames
+// sent by the server until the response ends.
+func probeWindowUpdate(c *config.Config) (*WindowUpdate, error) {
+	conn, err := spec.Dial(c)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	err = conn.Handshake()
+	if err != nil {
+		return nil, err
+	}
+
+	// Servers may increase the window of the connection right after
+	// the handshake. These WINDOW_UPDATE frames are not sent for the
+	// request body and are discarded before the ACK of a PING frame.
+	err = ping(conn, [8]byte{'h', '2', 's', 'p', 'e', 'c'})
+	if err != nil {
+		return nil, err
+	}
+
+	bodyLen := spec.DefaultWindowSize
+	if val, ok := conn.Settings[http2.SettingInitialWindowSize]; ok && int(val) < bodyLen {
+		bodyLen = int(val)
+	}
+
+	headers := spec.CommonHeaders(c)
+	headers[0].Value = "POST"
+
+	hp := http2.HeadersFrameParam{
+		StreamID:      1,
+		EndStream:     false,
+		EndHeaders:    true,
+		BlockFragment: conn.EncodeHeaders(headers),
+	}
+	err = conn.WriteHeaders(hp)
+	if err != nil {
+		return nil, err
+	}
+
+	wu := &WindowUpdate{BodyLength: bodyLen}
+
+	remain := bodyLen
+	for {
+		n := remain
+		if n > spec.DefaultFrameSize {
+			n = spec.DefaultFrameSize
+		}
+		remain -= n
+
+		err = conn.WriteData(1, remain == 0, spec.DummyBytes(n))
+		if err != nil {
+			return nil, err
+		}
+		wu.DataFrames++
+
+		if remain == 0 {
+			break
+		}
+	}
+
+	done := false
+	for !done {
+		ev := conn.WaitEvent()
+
+		switch event := ev.(type) {
+		case spec.WindowUpdateFrameEvent:
+			if event.Header().StreamID == 0 {
+				wu.ConnectionUpdates++
+				wu.ConnectionIncrement += int(event.Increment)
+			} else {
+				wu.StreamUpdates++
+				wu.StreamIncrement += int(event.Increment)
+			}
+		case spec.HeadersFrameEvent:
+			done = event.StreamEnded()
+		case spec.DataFrameEvent:
+			done = event.StreamEnded()
+		case spec.RSTStreamFrameEvent, spec.GoAwayFrameEvent, spec.ConnectionClosedEvent, spec.TimeoutEvent:
+			done = true
+		case spec.ErrorEvent:
+			return nil, errors.New(ev.String())
+		}
+	}
+
+	switch {
+	case wu.ConnectionUpdates == 0 && wu.StreamUpdates == 0:
+		wu.Strategy = "none"
+	case wu.ConnectionUpdates >= wu.DataFrames || wu.StreamUpdates >= wu.DataFrames:
+		wu.Strategy = "per DATA frame"
+	default:
+		wu.Strategy = "batched"
+	}
+
+	return wu, nil
+}
+
+// probeGoAway sends a GOAWAY frame right after a request and records
+// whether the server still completes the response.
+func probeGoAway(c *config.Config) (*GoAway, error) {
+	conn, err := spec.Dial(c)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	err = conn.Handshake()
+	if err != nil {
+		return nil, err
+	}
+
+	hp := http2.HeadersFrameParam{
+		StreamID:      1,
+		EndStream:     true,
+		EndHeaders:    true,
+		BlockFragment: conn.EncodeHeaders(spec.CommonHeaders(c)),
+	}
+	err = conn.WriteHeaders(hp)
+	if err != nil {
+		return nil, err
+	}
+
+	err = conn.WriteGoAway(0, http2.ErrCodeNo, []byte("h2spec probe"))
+	if err != nil {
+		return nil, err
+	}
+
+	g := &GoAway{}
+
+	for !g.ConnectionClosed {
+		ev := conn.WaitEvent()
+
+		switch event := ev.(type) {
+		case spec.HeadersFrameEvent:
+			if event.Header().StreamID == 1 && event.StreamEnded() {
+				g.ResponseCompleted = true
+			}
+		case spec.DataFrameEvent:
+			if event.Header().StreamID == 1 && event.StreamEnded() {
+				g.ResponseCompleted = true
+			}
+		case spec.GoAwayFrameEvent:
+			g.GoAwaySent = true
+			g.ErrorCode = event.ErrCode.String()
+		case spec.ConnectionClosedEvent, spec.ErrorEvent:
+			g.ConnectionClosed = true
+		case spec.TimeoutEvent:
+			return g, nil
+		}
+	}
+
+	return g, nil
+}
@@ -0,0 +1,48 @@
+package reporter
+
+import (
+	"fmt"
+
+	"github.com/summerwind/h2spec/baseline"
+	"github.com/summerwind/h2spec/log"
+)
+
+// BaselineComparison outputs the result of comparing test results
+// with a baseline.
+func BaselineComparison(b *baseline.Baseline, cmp *baseline.Comparison) {
+	log.SetIndentLevel(0)
+	log.Print("Baseline: \n\n")
+
+	log.SetIndentLevel(1)
+	log.Println(fmt.Sprintf("New failures (%d):", len(cmp.NewFailures)))
+	log.SetIndentLevel(2)
+	for _, tr := range cmp.NewFailures {
+		log.Println(red(fmt.Sprintf("× %s %s", tr.ID(), tr.TestCase.Desc)))
+	}
+	log.PrintBlankLine()
+
+	log.SetIndentLevel(1)
+	log.Println(fmt.Sprintf("Known failures (%d):", len(cmp.KnownFailures)))
+	log.SetIndentLevel(2)
+	for _, tr := range cmp.KnownFailures {
+		msg := fmt.Sprintf("%s %s", tr.ID(), tr.TestCase.Desc)
+		reason := b.Reasons[tr.ID()]
+		if reason != "" {
+			msg = fmt.Sprintf("%s (%s)", msg, reason)
+		}
+		log.Println(gray(msg))
+	}
+	log.PrintBlankLine()
+
+	if len(cmp.Fixed) > 0 {
+		log.SetIndentLevel(1)
+		log.Println(fmt.Sprintf("Now passing, remove them from the baseline (%d):", len(cmp.Fixed)))
+		log.SetIndentLevel(2)
+		for _, tr := range cmp.Fixed {
+			log.Println(green(fmt.Sprintf("✔ %s %s", tr.ID(), tr.TestCase.Desc)))
+		}
+		log.PrintBlankLine()
+	}
+
+	log.SetIndentLevel(0)
+}
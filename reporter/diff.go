@@ -0,0 +1,149 @@
+package reporter
+
+import (
+	"fmt"
+
+	"github.com/summerwind/h2spec/log"
+)
+
+// Diff represents the behavioural differences between two test runs.
+// Test cases are lined up by their ID, test cases that only exist in
+// one of the runs are ignored.
+type Diff struct {
+	PassToFail []*DiffEntry
+	FailToPass []*DiffEntry
+	FromSkip   []*DiffEntry
+	// EventChanges contains the test cases that failed in both runs
+	// but received a different sequence of events.
+	EventChanges []*DiffEntry
+}
+
+// DiffEntry represents a test case whose result differs between
+// two runs.
+type DiffEntry struct {
+	Old *JSONTestCase
+	New *JSONTestCase
+	// EventIndex is the index of the first event that differs, or -1
+	// if the events are the same.
+	EventIndex int
+}
+
+// HasRegressions returns true if a test case that passed in the old
+// run fails in the new run.
+func (d *Diff) HasRegressions() bool {
+	return len(d.PassToFail) > 0
+}
+
+// DiffJSONReports compares the results of the old report with the
+// new report.
+func DiffJSONReports(old, new *JSONTestReport) *Diff {
+	d := &Diff{}
+
+	oldTests := make(map[string]*JSONTestCase)
+	for _, tc := range old.TestCases() {
+		oldTests[tc.ID] = tc
+	}
+
+	for _, tc := range new.TestCases() {
+		otc, ok := oldTests[tc.ID]
+		if !ok {
+			continue
+		}
+
+		entry := &DiffEntry{
+			Old:        otc,
+			New:        tc,
+			EventIndex: diffEvents(otc.Events, tc.Events),
+		}
+
+		switch {
+		case otc.Status == tc.Status:
+			if tc.Status == JSONStatusFailed && entry.EventIndex >= 0 {
+				d.EventChanges = append(d.EventChanges, entry)
+			}
+		case otc.Status == JSONStatusNotRun || tc.Status == JSONStatusNotRun:
+			// A test case that was not run has no result to compare.
+		case otc.Status == JSONStatusSkipped:
+			d.FromSkip = append(d.FromSkip, entry)
+		case otc.Status == JSONStatusPassed && tc.Status == JSONStatusFailed:
+			d.PassToFail = append(d.PassToFail, entry)
+		case otc.Status == JSONStatusFailed && tc.Status == JSONStatusPassed:
+			d.FailToPass = append(d.FailToPass, entry)
+		}
+	}
+
+	return d
+}
+
+// TestCases returns all test cases of the report in the order they
+// were run.
+func (r *JSONTestReport) TestCases() []*JSONTestCase {
+	var tests []*JSONTestCase
+	for _, g := range r.Groups {
+		tests = append(tests, g.TestCases()...)
+	}
+	return tests
+}
+
+// TestCases returns all test cases of the group and its sub groups.
+func (g *JSONTestGroup) TestCases() []*JSONTestCase {
+	tests := append([]*JSONTestCase{}, g.Tests...)
+	for _, sg := range g.Groups {
+		tests = append(tests, sg.TestCases()...)
+	}
+	return tests
+}
+
+// diffEvents returns the index of the first event that differs
+// between the two sequences, or -1 if they are the same.
+func diffEvents(old, new []string) int {
+	for i := 0; i < len(old) || i < len(new); i++ {
+		if i >= len(old) || i >= len(new) || old[i] != new[i] {
+			return i
+		}
+	}
+	return -1
+}
+
+// PrintDiff outputs the behavioural differences between two runs.
+func PrintDiff(d *Diff) {
+	log.SetIndentLevel(0)
+	log.Print("Differences: \n\n")
+
+	printDiffEntries("Passed -> Failed", d.PassToFail, red)
+	printDiffEntries("Failed -> Passed", d.FailToPass, green)
+	printDiffEntries("Skipped -> Run", d.FromSkip, cyan)
+
+	log.SetIndentLevel(1)
+	log.Println(fmt.Sprintf("Failed with different events (%d):", len(d.EventChanges)))
+	for _, e := range d.EventChanges {
+		log.SetIndentLevel(2)
+		log.Println(yellow(fmt.Sprintf("%s %s", e.New.ID, e.New.Description)))
+		log.SetIndentLevel(3)
+		log.Println(gray(fmt.Sprintf("Event #%d", e.EventIndex+1)))
+		log.Println(gray(fmt.Sprintf("  Old: %s", eventAt(e.Old.Events, e.EventIndex))))
+		log.Println(gray(fmt.Sprintf("  New: %s", eventAt(e.New.Events, e.EventIndex))))
+	}
+	log.PrintBlankLine()
+
+	log.SetIndentLevel(0)
+}
+
+func printDiffEntries(title string, entries []*DiffEntry, color func(a ...interface{}) string) {
+	log.SetIndentLevel(1)
+	log.Println(fmt.Sprintf("%s (%d):", title, len(entries)))
+
+	log.SetIndentLevel(2)
+	for _, e := range entries {
+		msg := fmt.Sprintf("%s %s (%s -> %s)", e.New.ID, e.New.Description, e.Old.Status, e.New.Status)
+		log.Println(color(msg))
+	}
+	log.PrintBlankLine()
+}
+
+func eventAt(events []string, i int) string {
+	if i < 0 || i >= len(events) {
+		return "(none)"
+	}
+	return events[i]
+}
@@ -0,0 +1,219 @@
+package reporter
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/summerwind/h2spec/spec"
+)
+
+func TestDiffJSONReports(t *testing.T) {
+	tests := []struct {
+		id        string
+		old       string
+		new       string
+		oldEvents []string
+		newEvents []string
+		expected  string
+	}{
+		{id: "http2/1/1", old: JSONStatusPassed, new: JSONStatusFailed, expected: "pass-to-fail"},
+		{id: "http2/1/2", old: JSONStatusFailed, new: JSONStatusPassed, expected: "fail-to-pass"},
+		{id: "http2/1/3", old: JSONStatusSkipped, new: JSONStatusPassed, expected: "from-skip"},
+		{id: "http2/1/4", old: JSONStatusSkipped, new: JSONStatusFailed, expected: "from-skip"},
+		{id: "http2/1/5", old: JSONStatusPassed, new: JSONStatusSkipped, expected: ""},
+		{id: "http2/1/6", old: JSONStatusPassed, new: JSONStatusPassed, expected: ""},
+		{id: "http2/1/7", old: JSONStatusNotRun, new: JSONStatusFailed, expected: ""},
+		{id: "http2/1/8", old: JSONStatusPassed, new: JSONStatusNotRun, expected: ""},
+		{id: "http2/1/9", old: JSONStatusNotRun, new: JSONStatusNotRun, expected: ""},
+		{
+			id:        "http2/1/10",
+			old:       JSONStatusFailed,
+			new:       JSONStatusFailed,
+			oldEvents: []string{"SETTINGS Frame", "Timeout"},
+			newEvents: []string{"SETTINGS Frame", "Connection closed"},
+			expected:  "events",
+		},
+		{
+			id:        "http2/1/11",
+			old:       JSONStatusFailed,
+			new:       JSONStatusFailed,
+			oldEvents: []string{"SETTINGS Frame", "Timeout"},
+			newEvents: []string{"SETTINGS Frame", "Timeout"},
+			expected:  "",
+		},
+		{
+			id:        "http2/1/12",
+			old:       JSONStatusPassed,
+			new:       JSONStatusPassed,
+			oldEvents: []string{"DATA Frame"},
+			newEvents: []string{"HEADERS Frame"},
+			expected:  "",
+		},
+	}
+
+	oldGroup := &JSONTestGroup{ID: "http2/1"}
+	newGroup := &JSONTestGroup{ID: "http2/1"}
+	for _, tt := range tests {
+		oldGroup.Tests = append(oldGroup.Tests, &JSONTestCase{ID: tt.id, Status: tt.old, Events: tt.oldEvents})
+		newGroup.Tests = append(newGroup.Tests, &JSONTestCase{ID: tt.id, Status: tt.new, Events: tt.newEvents})
+	}
+
+	// Test cases that only exist in one of the runs are ignored.
+	oldGroup.Tests = append(oldGroup.Tests, &JSONTestCase{ID: "http2/2/1", Status: JSONStatusPassed})
+	newGroup.Tests = append(newGroup.Tests, &JSONTestCase{ID: "http2/3/1", Status: JSONStatusFailed})
+
+	old := &JSONTestReport{Groups: []*JSONTestGroup{{ID: "http2", Groups: []*JSONTestGroup{oldGroup}}}}
+	new := &JSONTestReport{Groups: []*JSONTestGroup{{ID: "http2", Groups: []*JSONTestGroup{newGroup}}}}
+
+	d := DiffJSONReports(old, new)
+
+	actual := map[string]string{}
+	categories := map[string][]*DiffEntry{
+		"pass-to-fail": d.PassToFail,
+		"fail-to-pass": d.FailToPass,
+		"from-skip":    d.FromSkip,
+		"events":       d.EventChanges,
+	}
+	for category, entries := range categories {
+		for _, e := range entries {
+			if prev, ok := actual[e.New.ID]; ok {
+				t.Errorf("%s - found in both %s and %s", e.New.ID, prev, category)
+			}
+			actual[e.New.ID] = category
+		}
+	}
+
+	for _, tt := range tests {
+		if actual[tt.id] != tt.expected {
+			t.Errorf("%s %s -> %s - expect: %q, got: %q", tt.id, tt.old, tt.new, tt.expected, actual[tt.id])
+		}
+	}
+
+	for _, id := range []string{"http2/2/1", "http2/3/1"} {
+		if category, ok := actual[id]; ok {
+			t.Errorf("%s - expect: ignored, got: %s", id, category)
+		}
+	}
+
+	if !d.HasRegressions() {
+		t.Error("expect regressions")
+	}
+
+	if len(d.EventChanges) == 1 && d.EventChanges[0].EventIndex != 1 {
+		t.Errorf("event index - expect: 1, got: %d", d.EventChanges[0].EventIndex)
+	}
+}
+
+func TestDiffEvents(t *testing.T) {
+	tests := []struct {
+		old      []string
+		new      []string
+		expected int
+	}{
+		{old: nil, new: nil, expected: -1},
+		{old: []string{"a", "b"}, new: []string{"a", "b"}, expected: -1},
+		{old: []string{"a", "b"}, new: []string{"a", "c"}, expected: 1},
+		{old: []string{"a"}, new: []string{"a", "b"}, expected: 1},
+		{old: []string{"a", "b"}, new: []string{"a"}, expected: 1},
+		{old: nil, new: []string{"a"}, expected: 0},
+	}
+
+	for i, tt := range tests {
+		if actual := diffEvents(tt.old, tt.new); actual != tt.expected {
+			t.Errorf("#%d - expect: %d, got: %d", i, tt.expected, actual)
+		}
+	}
+}
+
+// TestDiffWithPreviousReport compares the results of a run with the
+// JSON report of a previous run written to a file, as --compare does.
+func TestDiffWithPreviousReport(t *testing.T) {
+	newRun := func(errs ...error) []*spec.TestGroup {
+		root := &spec.TestGroup{Key: "http2"}
+		tg := &spec.TestGroup{Key: "http2", Section: "5.1"}
+		root.AddTestGroup(tg)
+
+		for i, err := range errs {
+			tc := &spec.TestCase{Desc: "test"}
+			tg.AddTestCase(tc)
+			tc.Result = spec.NewTestResult(tc, i+1, err, time.Duration(0), nil)
+		}
+
+		return []*spec.TestGroup{root}
+	}
+
+	failure := errors.New("failure")
+	path := filepath.Join(t.TempDir(), "prev.json")
+
+	err := writeJSONReport(NewJSONReport(newRun(nil, failure, spec.ErrSkipped), time.Second), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prev, err := LoadJSONReport(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := DiffJSONReports(prev, NewJSONReport(newRun(failure, nil, nil), time.Second))
+
+	if len(d.PassToFail) != 1 || d.PassToFail[0].New.ID != "http2/5.1/1" {
+		t.Errorf("passed -> failed - expect: http2/5.1/1, got: %v", diffIDs(d.PassToFail))
+	}
+	if len(d.FailToPass) != 1 || d.FailToPass[0].New.ID != "http2/5.1/2" {
+		t.Errorf("failed -> passed - expect: http2/5.1/2, got: %v", diffIDs(d.FailToPass))
+	}
+	if len(d.FromSkip) != 1 || d.FromSkip[0].New.ID != "http2/5.1/3" {
+		t.Errorf("skipped -> run - expect: http2/5.1/3, got: %v", diffIDs(d.FromSkip))
+	}
+}
+
+// TestDiffWithPreviousClientReport compares the results of a client
+// run with the JSON report of a previous one.
+func TestDiffWithPreviousClientReport(t *testing.T) {
+	newRun := func(errs ...error) *spec.ClientTestGroup {
+		root := &spec.ClientTestGroup{Key: "client"}
+		tg := &spec.ClientTestGroup{Key: "client", Section: "6.5"}
+		root.AddTestGroup(tg)
+
+		for _, err := range errs {
+			tc := &spec.ClientTestCase{Desc: "test"}
+			tg.AddTestCase(tc)
+			tc.Result = spec.NewClientTestResult(tc, err, time.Duration(0))
+		}
+
+		return root
+	}
+
+	failure := errors.New("failure")
+	path := filepath.Join(t.TempDir(), "prev.json")
+
+	err := writeJSONReport(NewClientJSONReport(newRun(nil, failure), time.Second), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prev, err := LoadJSONReport(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := DiffJSONReports(prev, NewClientJSONReport(newRun(failure, failure), time.Second))
+
+	if len(d.PassToFail) != 1 || d.PassToFail[0].New.ID != "client/6.5/1" {
+		t.Errorf("passed -> failed - expect: client/6.5/1, got: %v", diffIDs(d.PassToFail))
+	}
+	if len(d.FailToPass) != 0 {
+		t.Errorf("failed -> passed - expect: none, got: %v", diffIDs(d.FailToPass))
+	}
+}
+
+func diffIDs(entries []*DiffEntry) []string {
+	var ids []string
+	for _, e := range entries {
+		ids = append(ids, e.New.ID)
+	}
+	return ids
+}
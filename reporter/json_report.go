@@ -0,0 +1,333 @@
+package reporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/summerwind/h2spec/spec"
+)
+
+const (
+	JSONStatusPassed  = "passed"
+	JSONStatusFailed  = "failed"
+	JSONStatusSkipped = "skipped"
+	JSONStatusNotRun  = "not_run"
+)
+
+// JSONTestReport represents the JSON report format.
+type JSONTestReport struct {
+	Duration float64          `json:"duration"`
+	Tests    int              `json:"tests"`
+	Passed   int              `json:"passed"`
+	Skipped  int              `json:"skipped"`
+	Failed   int              `json:"failed"`
+	Flaky    int              `json:"flaky"`
+	NotRun   int              `json:"not_run,omitempty"`
+	TLS      *JSONTLSInfo     `json:"tls,omitempty"`
+	Groups   []*JSONTestGroup `json:"groups"`
+}
+
+// JSONTLSInfo represents the parameters negotiated in the TLS
+// handshake in the JSON report.
+type JSONTLSInfo struct {
+	Version     string `json:"version"`
+	CipherSuite string `json:"cipher_suite"`
+	ALPN        string `json:"alpn"`
+}
+
+// JSONTestGroup represents a test group in the JSON report.
+type JSONTestGroup struct {
+	ID       string           `json:"id"`
+	Name     string           `json:"name"`
+	Duration float64          `json:"duration"`
+	Passed   int              `json:"passed"`
+	Skipped  int              `json:"skipped"`
+	Failed   int              `json:"failed"`
+	Flaky    int              `json:"flaky"`
+	NotRun   int              `json:"not_run,omitempty"`
+	Tests    []*JSONTestCase  `json:"tests,omitempty"`
+	Groups   []*JSONTestGroup `json:"groups,omitempty"`
+}
+
+// JSONTestCase represents a test case in the JSON report.
+type JSONTestCase struct {
+	ID          string     `json:"id"`
+	Description string     `json:"description"`
+	Requirement string     `json:"requirement"`
+	Status      string     `json:"status"`
+	Timeout     bool       `json:"timeout,omitempty"`
+	Flaky       bool       `json:"flaky,omitempty"`
+	Crashed     bool       `json:"crashed_server,omitempty"`
+	Attempts    int        `json:"attempts"`
+	Duration    float64    `json:"duration"`
+	Handshake   float64    `json:"handshake"`
+	FirstFrame  float64    `json:"first_frame"`
+	MaxWait     float64    `json:"max_wait"`
+	SourceAddr  string     `json:"source_address,omitempty"`
+	Error       *JSONError `json:"error,omitempty"`
+	Events      []string   `json:"events,omitempty"`
+
+	RetriedErrors []*JSONError `json:"retried_errors,omitempty"`
+	Hooks         []*JSONHook  `json:"hooks,omitempty"`
+}
+
+// JSONHook represents the output of a hook command in the JSON
+// report.
+type JSONHook struct {
+	Name   string `json:"name"`
+	Output string `json:"output"`
+	Error  string `json:"error,omitempty"`
+}
+
+// JSONError represents the reason of a failed test case.
+type JSONError struct {
+	Expected []string `json:"expected,omitempty"`
+	Actual   string   `json:"actual,omitempty"`
+	Message  string   `json:"message,omitempty"`
+}
+
+// JSONReport writes a file which contains the JSON report generated
+// by test result of h2spec. info is nil if TLS is not used.
+func JSONReport(groups []*spec.TestGroup, d time.Duration, info *spec.TLSInfo, filePath string) error {
+	report := NewJSONReport(groups, d)
+
+	if info != nil {
+		report.TLS = &JSONTLSInfo{
+			Version:     info.Version,
+			CipherSuite: info.CipherSuite,
+			ALPN:        info.ALPN,
+		}
+	}
+
+	return writeJSONReport(report, filePath)
+}
+
+// ClientJSONReport writes a file which contains the JSON report
+// generated by test result of h2specd.
+func ClientJSONReport(group *spec.ClientTestGroup, d time.Duration, filePath string) error {
+	return writeJSONReport(NewClientJSONReport(group, d), filePath)
+}
+
+// LoadJSONReport reads the JSON report of the specified path.
+func LoadJSONReport(filePath string) (*JSONTestReport, error) {
+	buf, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var report JSONTestReport
+	err = json.Unmarshal(buf, &report)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filePath, err)
+	}
+
+	return &report, nil
+}
+
+// NewJSONReport returns the JSON report of the test groups.
+func NewJSONReport(groups []*spec.TestGroup, d time.Duration) *JSONTestReport {
+	report := &JSONTestReport{
+		Duration: d.Seconds(),
+	}
+
+	for _, tg := range groups {
+		jtg := convertJSONTestGroup(tg)
+		if jtg == nil {
+			continue
+		}
+
+		report.Passed += jtg.Passed
+		report.Skipped += jtg.Skipped
+		report.Failed += jtg.Failed
+		report.Flaky += jtg.Flaky
+		report.NotRun += jtg.NotRun
+		report.Groups = append(report.Groups, jtg)
+	}
+	report.Tests = report.Passed + report.Skipped + report.Failed
+
+	return report
+}
+
+// NewClientJSONReport returns the JSON report of the client test
+// group.
+func NewClientJSONReport(group *spec.ClientTestGroup, d time.Duration) *JSONTestReport {
+	report := &JSONTestReport{
+		Duration: d.Seconds(),
+		Passed:   group.PassedCount,
+		Skipped:  group.SkippedCount,
+		Failed:   group.FailedCount,
+	}
+	report.Tests = report.Passed + report.Skipped + report.Failed
+
+	jtg := convertClientJSONTestGroup(group)
+	if jtg != nil {
+		report.Groups = append(report.Groups, jtg)
+	}
+
+	return report
+}
+
+func writeJSONReport(report *JSONTestReport, filePath string) error {
+	buf, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filePath, buf, os.ModePerm)
+}
+
+// convertJSONTestGroup converts the test group into the JSON report
+// format. It returns nil if no test case of the group was run.
+func convertJSONTestGroup(tg *spec.TestGroup) *JSONTestGroup {
+	jtg := &JSONTestGroup{
+		ID:       tg.ID(),
+		Name:     tg.Title(),
+		Duration: tg.Duration.Seconds(),
+		Passed:   tg.PassedCount,
+		Skipped:  tg.SkippedCount,
+		Failed:   tg.FailedCount,
+		Flaky:    tg.FlakyCount,
+		NotRun:   tg.NotRunCount,
+	}
+
+	tests := append(tg.Tests, tg.StrictTests...)
+	for _, tc := range tests {
+		if tc.Result == nil {
+			continue
+		}
+		jtg.Tests = append(jtg.Tests, convertJSONTestCase(tc.Result))
+	}
+
+	for _, g := range tg.Groups {
+		jg := convertJSONTestGroup(g)
+		if jg != nil {
+			jtg.Groups = append(jtg.Groups, jg)
+		}
+	}
+
+	if len(jtg.Tests) == 0 && len(jtg.Groups) == 0 {
+		return nil
+	}
+
+	return jtg
+}
+
+// convertClientJSONTestGroup converts the client test group into the
+// JSON report format. It returns nil if no test case of the group was
+// run.
+func convertClientJSONTestGroup(tg *spec.ClientTestGroup) *JSONTestGroup {
+	jtg := &JSONTestGroup{
+		ID:      tg.ID(),
+		Name:    tg.Title(),
+		Passed:  tg.PassedCount,
+		Skipped: tg.SkippedCount,
+		Failed:  tg.FailedCount,
+	}
+
+	for _, tc := range tg.Tests {
+		tr := tc.Result
+		if tr == nil {
+			continue
+		}
+
+		jtc := &JSONTestCase{
+			ID:          tc.ID(),
+			Description: tc.Desc,
+			Requirement: tc.Requirement,
+			Status:      JSONStatusPassed,
+			Duration:    tr.Duration.Seconds(),
+			Attempts:    1,
+			Events:      tr.Events,
+		}
+
+		if tr.Skipped {
+			jtc.Status = JSONStatusSkipped
+		} else if tr.Failed {
+			jtc.Status = JSONStatusFailed
+			jtc.Error = convertJSONError(tr.Error)
+		}
+
+		jtg.Duration += jtc.Duration
+		jtg.Tests = append(jtg.Tests, jtc)
+	}
+
+	for _, g := range tg.Groups {
+		jg := convertClientJSONTestGroup(g)
+		if jg != nil {
+			jtg.Duration += jg.Duration
+			jtg.Groups = append(jtg.Groups, jg)
+		}
+	}
+
+	if len(jtg.Tests) == 0 && len(jtg.Groups) == 0 {
+		return nil
+	}
+
+	return jtg
+}
+
+func convertJSONTestCase(tr *spec.TestResult) *JSONTestCase {
+	jtc := &JSONTestCase{
+		ID:          tr.ID(),
+		Description: tr.TestCase.Desc,
+		Requirement: tr.TestCase.Requirement,
+		Status:      JSONStatusPassed,
+		Timeout:     tr.Timeout,
+		Flaky:       tr.Flaky,
+		Crashed:     tr.CrashedServer,
+		Attempts:    tr.Attempts,
+		Duration:    tr.Duration.Seconds(),
+		Handshake:   tr.Handshake.Seconds(),
+		FirstFrame:  tr.FirstFrame.Seconds(),
+		MaxWait:     tr.MaxWait.Seconds(),
+		Events:      tr.Events,
+	}
+
+	if tr.SourceAddr != nil {
+		jtc.SourceAddr = tr.SourceAddr.String()
+	}
+
+	if tr.Skipped {
+		jtc.Status = JSONStatusSkipped
+	} else if tr.NotRun {
+		jtc.Status = JSONStatusNotRun
+		jtc.Error = convertJSONError(tr.Error)
+	} else if tr.Failed {
+		jtc.Status = JSONStatusFailed
+		jtc.Error = convertJSONError(tr.Error)
+	}
+
+	for _, err := range tr.RetriedErrors {
+		jtc.RetriedErrors = append(jtc.RetriedErrors, convertJSONError(err))
+	}
+
+	for _, h := range tr.Hooks {
+		jh := &JSONHook{
+			Name:   h.Name,
+			Output: h.Output,
+		}
+		if h.Error != nil {
+			jh.Error = h.Error.Error()
+		}
+		jtc.Hooks = append(jtc.Hooks, jh)
+	}
+
+	return jtc
+}
+
+func convertJSONError(err error) *JSONError {
+	testErr, ok := err.(*spec.TestError)
+	if ok {
+		return &JSONError{
+			Expected: testErr.Expected,
+			Actual:   testErr.Actual,
+		}
+	}
+
+	return &JSONError{
+		Message: err.Error(),
+	}
+}
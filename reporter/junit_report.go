@@ -18,26 +18,38 @@ type JUnitTestReport struct {
 
 // JUnitTestSuite represents the testsuite element of JUnit XML format.
 type JUnitTestSuite struct {
-	XMLName   xml.Name         `xml:"testsuite"`
-	Name      string           `xml:"name,attr"`
-	Package   string           `xml:"package,attr"`
-	ID        string           `xml:"id,attr"`
-	Tests     int              `xml:"tests,attr"`
-	Skipped   int              `xml:"skipped,attr"`
-	Failures  int              `xml:"failures,attr"`
-	Errors    int              `xml:"errors,attr"`
-	TestCases []*JUnitTestCase `xml:"testcase"`
+	XMLName    xml.Name         `xml:"testsuite"`
+	Name       string           `xml:"name,attr"`
+	Package    string           `xml:"package,attr"`
+	ID         string           `xml:"id,attr"`
+	Tests      int              `xml:"tests,attr"`
+	Skipped    int              `xml:"skipped,attr"`
+	Failures   int              `xml:"failures,attr"`
+	Errors     int              `xml:"errors,attr"`
+	Properties []*JUnitProperty `xml:"properties>property,omitempty"`
+	TestCases  []*JUnitTestCase `xml:"testcase"`
+}
+
+// JUnitProperty represents the property element of JUnit XML format.
+type JUnitProperty struct {
+	XMLName xml.Name `xml:"property"`
+	Name    string   `xml:"name,attr"`
+	Value   string   `xml:"value,attr"`
 }
 
 // JUnitTestCase represents the testcase element of JUnit XML format.
 type JUnitTestCase struct {
 	XMLName   xml.Name      `xml:"testcase"`
+	Name      string        `xml:"name,attr,omitempty"`
 	Package   string        `xml:"package,attr"`
 	ClassName string        `xml:"classname,attr"`
 	Time      string        `xml:"time,attr"`
 	Failure   *JUnitFailure `xml:"failure"`
 	Skipped   *JUnitSkipped `xml:"skipped"`
 	Error     *JUnitError   `xml:"error"`
+
+	FlakyFailures []*JUnitFlakyFailure `xml:"flakyFailure"`
+	SystemOut     *JUnitSystemOut      `xml:"system-out"`
 }
 
 // JUnitFailure represents the failure element of JUnit XML format.
@@ -52,6 +64,21 @@ type JUnitSkipped struct {
 	Content string   `xml:",innerxml"`
 }
 
+// JUnitFlakyFailure represents the flakyFailure element of JUnit XML
+// format. It is used for a failed attempt of a test case that passed
+// after being retried.
+type JUnitFlakyFailure struct {
+	XMLName xml.Name `xml:"flakyFailure"`
+	Content string   `xml:",chardata"`
+}
+
+// JUnitSystemOut represents the system-out element of JUnit XML
+// format. It contains the output of the hook commands.
+type JUnitSystemOut struct {
+	XMLName xml.Name `xml:"system-out"`
+	Content string   `xml:",chardata"`
+}
+
 // JUnitSkipped represents the error element of JUnit XML format.
 type JUnitError struct {
 	XMLName xml.Name `xml:"error"`
@@ -59,12 +86,48 @@ type JUnitError struct {
 }
 
 // JUnitReport writes a file which contains the JUnit report generated
-// by test result of h2spec.
-func JUnitReport(groups []*spec.TestGroup, filePath string) error {
+// by test result of h2spec. The parameters negotiated in the TLS
+// handshake are recorded as properties of each test suite unless info
+// is nil.
+func JUnitReport(groups []*spec.TestGroup, info *spec.TLSInfo, filePath string) error {
 	report := JUnitTestReport{
 		TestSuites: convertJUnitReport(groups),
 	}
 
+	if info != nil {
+		props := []*JUnitProperty{
+			{Name: "tls.version", Value: info.Version},
+			{Name: "tls.cipher_suite", Value: info.CipherSuite},
+			{Name: "tls.alpn", Value: info.ALPN},
+		}
+
+		for _, ts := range report.TestSuites {
+			if ts != nil {
+				ts.Properties = props
+			}
+		}
+	}
+
+	return writeJUnitReport(&report, filePath)
+}
+
+// LoadJUnitReport reads the JUnit report of the specified path.
+func LoadJUnitReport(filePath string) (*JUnitTestReport, error) {
+	buf, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var report JUnitTestReport
+	err = xml.Unmarshal(buf, &report)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filePath, err)
+	}
+
+	return &report, nil
+}
+
+func writeJUnitReport(report *JUnitTestReport, filePath string) error {
 	buf, err := xml.MarshalIndent(report, "", "  ")
 	if err != nil {
 		return err
@@ -101,6 +164,7 @@ func convertJUnitReport(groups []*spec.TestGroup) []*JUnitTestSuite {
 			}
 
 			jtc := &JUnitTestCase{
+				Name:      tc.Result.ID(),
 				Package:   tg.ID(),
 				ClassName: tc.Desc,
 				Time:      fmt.Sprintf("%.04f", tc.Result.Duration.Seconds()),
@@ -110,6 +174,11 @@ func convertJUnitReport(groups []*spec.TestGroup) []*JUnitTestSuite {
 			if tc.Result.Skipped {
 				jts.Skipped += 1
 				jtc.Skipped = &JUnitSkipped{}
+			} else if tc.Result.NotRun {
+				jts.Skipped += 1
+				jtc.Skipped = &JUnitSkipped{
+					Content: fmt.Sprintf("Not run: %s", tc.Result.Error),
+				}
 			} else if tc.Result.Failed {
 				switch tc.Result.Error.(type) {
 				case spec.TestError:
@@ -125,13 +194,30 @@ func convertJUnitReport(groups []*spec.TestGroup) []*JUnitTestSuite {
 				default:
 					jts.Errors += 1
 
-					err := tc.Result.Error
+					content := tc.Result.Error.Error()
+					if tc.Result.CrashedServer && tc.Result.Error != spec.ErrServerCrashed {
+						content = fmt.Sprintf("%s\n(crashed server)", content)
+					}
 					jtc.Error = &JUnitError{
-						Content: err.Error(),
+						Content: content,
 					}
 				}
 			}
 
+			if tc.Result.Flaky {
+				for _, err := range tc.Result.RetriedErrors {
+					jtc.FlakyFailures = append(jtc.FlakyFailures, &JUnitFlakyFailure{
+						Content: err.Error(),
+					})
+				}
+			}
+
+			if len(tc.Result.Hooks) > 0 {
+				jtc.SystemOut = &JUnitSystemOut{
+					Content: junitHookOutput(tc.Result.Hooks),
+				}
+			}
+
 			jts.TestCases = append(jts.TestCases, jtc)
 		}
 
@@ -141,3 +227,17 @@ func convertJUnitReport(groups []*spec.TestGroup) []*JUnitTestSuite {
 
 	return ts
 }
+
+// junitHookOutput returns the output of the hook commands labeled
+// with their names.
+func junitHookOutput(hooks []*spec.HookOutput) string {
+	var out strings.Builder
+	for _, h := range hooks {
+		fmt.Fprintf(&out, "[%s]", h.Name)
+		if h.Error != nil {
+			fmt.Fprintf(&out, " %v", h.Error)
+		}
+		fmt.Fprintf(&out, "\n%s", h.Output)
+	}
+	return out.String()
+}
@@ -0,0 +1,339 @@
+package reporter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MergeReports merges the JSON or JUnit reports of the specified paths,
+// such as the partial reports of shards, and writes the merged report
+// to the output path. All the reports must have the same format.
+func MergeReports(filePaths []string, output string) error {
+	if len(filePaths) == 0 {
+		return errors.New("No reports to merge")
+	}
+
+	junit := false
+	for i, filePath := range filePaths {
+		buf, err := ioutil.ReadFile(filePath)
+		if err != nil {
+			return err
+		}
+
+		if i == 0 {
+			junit = isJUnitReport(buf)
+		} else if junit != isJUnitReport(buf) {
+			return fmt.Errorf("%s: Report format differs from %s", filePath, filePaths[0])
+		}
+	}
+
+	if junit {
+		var reports []*JUnitTestReport
+		for _, filePath := range filePaths {
+			report, err := LoadJUnitReport(filePath)
+			if err != nil {
+				return err
+			}
+			reports = append(reports, report)
+		}
+
+		merged, err := MergeJUnitReports(reports)
+		if err != nil {
+			return err
+		}
+
+		return writeJUnitReport(merged, output)
+	}
+
+	var reports []*JSONTestReport
+	for _, filePath := range filePaths {
+		report, err := LoadJSONReport(filePath)
+		if err != nil {
+			return err
+		}
+		reports = append(reports, report)
+	}
+
+	merged, err := MergeJSONReports(reports)
+	if err != nil {
+		return err
+	}
+
+	return writeJSONReport(merged, output)
+}
+
+// MergeJSONReports merges the JSON reports into one. Groups with the
+// same ID are merged and their totals are recomputed from their test
+// cases. The duration of the merged report and its groups is the
+// longest one of the reports, since the shards run in parallel. An
+// error is returned if a test case appears more than once, since it
+// would be counted twice.
+func MergeJSONReports(reports []*JSONTestReport) (*JSONTestReport, error) {
+	merged := &JSONTestReport{}
+	keys := newKeyOrder()
+
+	seen := map[string]bool{}
+	for _, report := range reports {
+		err := checkJSONTestIDs(report.Groups, seen)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var groups []*JSONTestGroup
+	for _, report := range reports {
+		if report.Duration > merged.Duration {
+			merged.Duration = report.Duration
+		}
+		if merged.TLS == nil {
+			merged.TLS = report.TLS
+		}
+		groups = mergeJSONTestGroups(groups, report.Groups, keys)
+	}
+
+	for _, jtg := range groups {
+		updateJSONTestGroup(jtg)
+
+		merged.Passed += jtg.Passed
+		merged.Skipped += jtg.Skipped
+		merged.Failed += jtg.Failed
+		merged.Flaky += jtg.Flaky
+		merged.NotRun += jtg.NotRun
+	}
+	merged.Tests = merged.Passed + merged.Skipped + merged.Failed
+	merged.Groups = groups
+
+	return merged, nil
+}
+
+// checkJSONTestIDs returns an error if the ID of a test case of the
+// groups has already been seen.
+func checkJSONTestIDs(groups []*JSONTestGroup, seen map[string]bool) error {
+	for _, jtg := range groups {
+		for _, jtc := range jtg.Tests {
+			if seen[jtc.ID] {
+				return fmt.Errorf("Duplicate test case in reports: %s", jtc.ID)
+			}
+			seen[jtc.ID] = true
+		}
+
+		err := checkJSONTestIDs(jtg.Groups, seen)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// mergeJSONTestGroups merges the groups into the merged groups and
+// returns them sorted by ID.
+func mergeJSONTestGroups(merged, groups []*JSONTestGroup, keys *keyOrder) []*JSONTestGroup {
+	index := map[string]*JSONTestGroup{}
+	for _, jtg := range merged {
+		index[jtg.ID] = jtg
+	}
+
+	for _, jtg := range groups {
+		mg, ok := index[jtg.ID]
+		if !ok {
+			mg = &JSONTestGroup{
+				ID:   jtg.ID,
+				Name: jtg.Name,
+			}
+			index[jtg.ID] = mg
+			keys.add(jtg.ID)
+			merged = append(merged, mg)
+		}
+
+		if jtg.Duration > mg.Duration {
+			mg.Duration = jtg.Duration
+		}
+		mg.Tests = append(mg.Tests, jtg.Tests...)
+		mg.Groups = mergeJSONTestGroups(mg.Groups, jtg.Groups, keys)
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		return keys.less(merged[i].ID, merged[j].ID)
+	})
+
+	return merged
+}
+
+// updateJSONTestGroup sorts the test cases of the group and recomputes
+// the totals of the group and its sub groups.
+func updateJSONTestGroup(jtg *JSONTestGroup) {
+	jtg.Passed, jtg.Skipped, jtg.Failed, jtg.Flaky, jtg.NotRun = 0, 0, 0, 0, 0
+
+	sort.SliceStable(jtg.Tests, func(i, j int) bool {
+		return testSeq(jtg.Tests[i].ID) < testSeq(jtg.Tests[j].ID)
+	})
+
+	for _, jtc := range jtg.Tests {
+		switch jtc.Status {
+		case JSONStatusPassed:
+			jtg.Passed++
+		case JSONStatusSkipped:
+			jtg.Skipped++
+		case JSONStatusFailed:
+			jtg.Failed++
+		case JSONStatusNotRun:
+			jtg.NotRun++
+		}
+
+		if jtc.Flaky {
+			jtg.Flaky++
+		}
+	}
+
+	for _, g := range jtg.Groups {
+		updateJSONTestGroup(g)
+
+		jtg.Passed += g.Passed
+		jtg.Skipped += g.Skipped
+		jtg.Failed += g.Failed
+		jtg.Flaky += g.Flaky
+		jtg.NotRun += g.NotRun
+	}
+}
+
+// MergeJUnitReports merges the JUnit reports into one. Test suites
+// with the same package are merged and their totals are recomputed
+// from their test cases. An error is returned if a test case appears
+// more than once, since it would be counted twice.
+func MergeJUnitReports(reports []*JUnitTestReport) (*JUnitTestReport, error) {
+	merged := &JUnitTestReport{}
+	keys := newKeyOrder()
+	index := map[string]*JUnitTestSuite{}
+	seen := map[string]bool{}
+
+	for _, report := range reports {
+		for _, ts := range report.TestSuites {
+			// Test cases of reports of older versions have no name.
+			for _, tc := range ts.TestCases {
+				if tc.Name == "" {
+					continue
+				}
+				if seen[tc.Name] {
+					return nil, fmt.Errorf("Duplicate test case in reports: %s", tc.Name)
+				}
+				seen[tc.Name] = true
+			}
+
+			ms, ok := index[ts.Package]
+			if !ok {
+				ms = &JUnitTestSuite{
+					Name:       ts.Name,
+					Package:    ts.Package,
+					ID:         ts.ID,
+					Properties: ts.Properties,
+				}
+				index[ts.Package] = ms
+				keys.add(ts.Package)
+				merged.TestSuites = append(merged.TestSuites, ms)
+			}
+
+			ms.TestCases = append(ms.TestCases, ts.TestCases...)
+		}
+	}
+
+	sort.SliceStable(merged.TestSuites, func(i, j int) bool {
+		return keys.less(merged.TestSuites[i].Package, merged.TestSuites[j].Package)
+	})
+
+	for _, ts := range merged.TestSuites {
+		sort.SliceStable(ts.TestCases, func(i, j int) bool {
+			return testSeq(ts.TestCases[i].Name) < testSeq(ts.TestCases[j].Name)
+		})
+
+		for _, tc := range ts.TestCases {
+			ts.Tests++
+			switch {
+			case tc.Skipped != nil:
+				ts.Skipped++
+			case tc.Failure != nil:
+				ts.Failures++
+			case tc.Error != nil:
+				ts.Errors++
+			}
+		}
+	}
+
+	return merged, nil
+}
+
+// keyOrder orders IDs by their key in the order the keys are first
+// seen, and then by their section numbers.
+type keyOrder struct {
+	keys map[string]int
+}
+
+func newKeyOrder() *keyOrder {
+	return &keyOrder{keys: map[string]int{}}
+}
+
+// add registers the key of the ID if it has not been seen yet.
+func (o *keyOrder) add(id string) {
+	o.index(strings.SplitN(id, "/", 2)[0])
+}
+
+func (o *keyOrder) index(key string) int {
+	i, ok := o.keys[key]
+	if !ok {
+		i = len(o.keys)
+		o.keys[key] = i
+	}
+	return i
+}
+
+// less returns true if the ID a is ordered before the ID b.
+func (o *keyOrder) less(a, b string) bool {
+	ac := strings.SplitN(a, "/", 2)
+	bc := strings.SplitN(b, "/", 2)
+
+	ai, bi := o.index(ac[0]), o.index(bc[0])
+	if ai != bi {
+		return ai < bi
+	}
+	if len(ac) != len(bc) {
+		return len(ac) < len(bc)
+	}
+	if len(ac) == 1 {
+		return false
+	}
+
+	an := strings.Split(ac[1], ".")
+	bn := strings.Split(bc[1], ".")
+	for i := 0; i < len(an) && i < len(bn); i++ {
+		x, _ := strconv.Atoi(an[i])
+		y, _ := strconv.Atoi(bn[i])
+		if x != y {
+			return x < y
+		}
+	}
+
+	return len(an) < len(bn)
+}
+
+// testSeq returns the sequence number of the test case of the ID, or
+// 0 if the ID has no sequence number.
+func testSeq(id string) int {
+	comps := strings.Split(id, "/")
+	if len(comps) != 3 {
+		return 0
+	}
+
+	seq, _ := strconv.Atoi(comps[2])
+	return seq
+}
+
+// isJUnitReport returns true if the content of the report is XML
+// rather than JSON.
+func isJUnitReport(buf []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(buf), []byte("<"))
+}
@@ -0,0 +1,289 @@
+package reporter
+
+import (
+	"strings"
+	"testing"
+)
+
+func jsonTest(id, status string, flaky bool) *JSONTestCase {
+	return &JSONTestCase{ID: id, Status: status, Flaky: flaky}
+}
+
+// jsonShards returns the reports of two shards of a run. The groups
+// http2 and http2/5 and http2/5.1 appear in both shards.
+func jsonShards() []*JSONTestReport {
+	shard1 := &JSONTestReport{
+		Duration: 1.5,
+		Groups: []*JSONTestGroup{
+			{
+				ID:       "http2",
+				Duration: 1,
+				Groups: []*JSONTestGroup{
+					{
+						ID: "http2/5",
+						Groups: []*JSONTestGroup{
+							{
+								ID: "http2/5.1",
+								Tests: []*JSONTestCase{
+									jsonTest("http2/5.1/3", JSONStatusFailed, false),
+									jsonTest("http2/5.1/1", JSONStatusPassed, true),
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	shard2 := &JSONTestReport{
+		Duration: 2,
+		Groups: []*JSONTestGroup{
+			{
+				ID: "generic",
+				Groups: []*JSONTestGroup{
+					{
+						ID:    "generic/1",
+						Tests: []*JSONTestCase{jsonTest("generic/1/1", JSONStatusPassed, false)},
+					},
+				},
+			},
+			{
+				ID:       "http2",
+				Duration: 0.5,
+				Groups: []*JSONTestGroup{
+					{
+						ID: "http2/5",
+						Groups: []*JSONTestGroup{
+							{
+								ID:    "http2/5.1",
+								Tests: []*JSONTestCase{jsonTest("http2/5.1/2", JSONStatusNotRun, false)},
+							},
+						},
+					},
+					{
+						ID:    "http2/4",
+						Tests: []*JSONTestCase{jsonTest("http2/4/1", JSONStatusSkipped, false)},
+					},
+				},
+			},
+		},
+	}
+
+	return []*JSONTestReport{shard1, shard2}
+}
+
+func TestMergeJSONReports(t *testing.T) {
+	merged, err := MergeJSONReports(jsonShards())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The shards run in parallel.
+	if merged.Duration != 2 {
+		t.Errorf("duration - expect: 2, got: %v", merged.Duration)
+	}
+
+	totals := []int{merged.Tests, merged.Passed, merged.Skipped, merged.Failed, merged.Flaky, merged.NotRun}
+	if expected := []int{4, 2, 1, 1, 1, 1}; !equalInts(totals, expected) {
+		t.Errorf("totals - expect: %v, got: %v", expected, totals)
+	}
+
+	// The groups of each key are in the order the keys are first seen.
+	if ids := groupIDs(merged.Groups); ids != "http2 generic" {
+		t.Errorf("groups - expect: http2 generic, got: %s", ids)
+	}
+
+	h2 := merged.Groups[0]
+	if h2.Duration != 1 {
+		t.Errorf("http2 duration - expect: 1, got: %v", h2.Duration)
+	}
+
+	if ids := groupIDs(h2.Groups); ids != "http2/4 http2/5" {
+		t.Errorf("http2 groups - expect: http2/4 http2/5, got: %s", ids)
+	}
+
+	tests := []struct {
+		group    *JSONTestGroup
+		expected []int
+	}{
+		{group: h2, expected: []int{1, 1, 1, 1, 1}},
+		{group: h2.Groups[0], expected: []int{0, 1, 0, 0, 0}},
+		{group: h2.Groups[1], expected: []int{1, 0, 1, 1, 1}},
+		{group: h2.Groups[1].Groups[0], expected: []int{1, 0, 1, 1, 1}},
+		{group: merged.Groups[1], expected: []int{1, 0, 0, 0, 0}},
+	}
+
+	for _, tt := range tests {
+		g := tt.group
+		counts := []int{g.Passed, g.Skipped, g.Failed, g.Flaky, g.NotRun}
+		if !equalInts(counts, tt.expected) {
+			t.Errorf("%s - expect: %v, got: %v", g.ID, tt.expected, counts)
+		}
+	}
+
+	var ids []string
+	for _, jtc := range h2.Groups[1].Groups[0].Tests {
+		ids = append(ids, jtc.ID)
+	}
+	if s := strings.Join(ids, " "); s != "http2/5.1/1 http2/5.1/2 http2/5.1/3" {
+		t.Errorf("http2/5.1 tests - expect: http2/5.1/1 http2/5.1/2 http2/5.1/3, got: %s", s)
+	}
+}
+
+func TestMergeJSONReportsDuplicate(t *testing.T) {
+	reports := jsonShards()
+	reports = append(reports, jsonShards()[1])
+
+	_, err := MergeJSONReports(reports)
+	if err == nil {
+		t.Fatal("expect an error for the duplicate test case")
+	}
+	if !strings.Contains(err.Error(), "generic/1/1") {
+		t.Errorf("expect the ID of the duplicate test case, got: %v", err)
+	}
+}
+
+func TestMergeJUnitReports(t *testing.T) {
+	reports := []*JUnitTestReport{
+		{
+			TestSuites: []*JUnitTestSuite{
+				{
+					Package: "http2/5.1",
+					TestCases: []*JUnitTestCase{
+						{Name: "http2/5.1/3", Failure: &JUnitFailure{}},
+						{Name: "http2/5.1/1"},
+					},
+				},
+			},
+		},
+		{
+			TestSuites: []*JUnitTestSuite{
+				{
+					Package: "http2/5.1",
+					TestCases: []*JUnitTestCase{
+						{Name: "http2/5.1/2", Skipped: &JUnitSkipped{}},
+					},
+				},
+				{
+					Package: "http2/4",
+					TestCases: []*JUnitTestCase{
+						{Name: "http2/4/1", Error: &JUnitError{}},
+					},
+				},
+			},
+		},
+	}
+
+	merged, err := MergeJUnitReports(reports)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(merged.TestSuites) != 2 {
+		t.Fatalf("expect: 2 test suites, got: %d", len(merged.TestSuites))
+	}
+
+	tests := []struct {
+		pkg      string
+		expected []int
+		names    string
+	}{
+		{pkg: "http2/4", expected: []int{1, 0, 0, 1}, names: "http2/4/1"},
+		{pkg: "http2/5.1", expected: []int{3, 1, 1, 0}, names: "http2/5.1/1 http2/5.1/2 http2/5.1/3"},
+	}
+
+	for i, tt := range tests {
+		ts := merged.TestSuites[i]
+		if ts.Package != tt.pkg {
+			t.Errorf("#%d - expect: %s, got: %s", i, tt.pkg, ts.Package)
+			continue
+		}
+
+		counts := []int{ts.Tests, ts.Skipped, ts.Failures, ts.Errors}
+		if !equalInts(counts, tt.expected) {
+			t.Errorf("%s - expect: %v, got: %v", ts.Package, tt.expected, counts)
+		}
+
+		var names []string
+		for _, tc := range ts.TestCases {
+			names = append(names, tc.Name)
+		}
+		if s := strings.Join(names, " "); s != tt.names {
+			t.Errorf("%s - expect: %s, got: %s", ts.Package, tt.names, s)
+		}
+	}
+
+	reports = append(reports, reports[0])
+	_, err = MergeJUnitReports(reports)
+	if err == nil {
+		t.Error("expect an error for the duplicate test case")
+	}
+}
+
+func TestKeyOrderLess(t *testing.T) {
+	keys := newKeyOrder()
+	keys.add("http2/5")
+	keys.add("generic/1")
+
+	tests := []struct {
+		a, b string
+		less bool
+	}{
+		{a: "http2/5", b: "generic/1", less: true},
+		{a: "generic/1", b: "http2/5", less: false},
+		{a: "http2", b: "http2/4", less: true},
+		{a: "http2/4", b: "http2/5.1", less: true},
+		{a: "http2/5", b: "http2/5.1", less: true},
+		{a: "http2/5.1", b: "http2/5", less: false},
+		{a: "http2/5.2", b: "http2/5.10", less: true},
+		{a: "http2/6.9.1", b: "http2/6.10", less: true},
+		{a: "http2/5.1", b: "http2/5.1", less: false},
+		{a: "generic/1", b: "hpack/1", less: true},
+	}
+
+	for i, tt := range tests {
+		if less := keys.less(tt.a, tt.b); less != tt.less {
+			t.Errorf("#%d %s < %s - expect: %v, got: %v", i, tt.a, tt.b, tt.less, less)
+		}
+	}
+}
+
+func TestTestSeq(t *testing.T) {
+	tests := []struct {
+		id  string
+		seq int
+	}{
+		{id: "http2/5.1/3", seq: 3},
+		{id: "generic/1/12", seq: 12},
+		{id: "http2/5.1", seq: 0},
+		{id: "http2", seq: 0},
+		{id: "", seq: 0},
+	}
+
+	for _, tt := range tests {
+		if seq := testSeq(tt.id); seq != tt.seq {
+			t.Errorf("%s - expect: %d, got: %d", tt.id, tt.seq, seq)
+		}
+	}
+}
+
+func groupIDs(groups []*JSONTestGroup) string {
+	var ids []string
+	for _, jtg := range groups {
+		ids = append(ids, jtg.ID)
+	}
+	return strings.Join(ids, " ")
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
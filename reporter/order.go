@@ -0,0 +1,104 @@
+package reporter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/summerwind/h2spec/log"
+	"github.com/summerwind/h2spec/spec"
+)
+
+// OrderDependence represents a test case whose result depends on the
+// order in which the test cases were run.
+type OrderDependence struct {
+	ID   string
+	Desc string
+	// Statuses contains the status of the test case in each order.
+	Statuses []string
+}
+
+// CompareOrders returns the test cases whose status differs between
+// the runs of the test suite in different orders. Test cases that
+// were not run in one of the orders are ignored.
+func CompareOrders(runs [][]*spec.TestResult) []*OrderDependence {
+	if len(runs) == 0 {
+		return nil
+	}
+
+	statuses := make([]map[string]string, len(runs))
+	for i, results := range runs {
+		statuses[i] = map[string]string{}
+		for _, tr := range results {
+			statuses[i][tr.ID()] = resultStatus(tr)
+		}
+	}
+
+	var deps []*OrderDependence
+	for _, tr := range runs[0] {
+		id := tr.ID()
+		dep := &OrderDependence{
+			ID:   id,
+			Desc: tr.TestCase.Desc,
+		}
+
+		differs := false
+		for _, s := range statuses {
+			status, ok := s[id]
+			if !ok {
+				differs = false
+				break
+			}
+
+			if status != statuses[0][id] {
+				differs = true
+			}
+			dep.Statuses = append(dep.Statuses, status)
+		}
+
+		if differs {
+			deps = append(deps, dep)
+		}
+	}
+
+	return deps
+}
+
+// OrderDependences outputs the test cases whose result depends on
+// the order in which the test cases were run, with the seed of each
+// order.
+func OrderDependences(seeds []int64, deps []*OrderDependence) {
+	log.SetIndentLevel(0)
+	log.Print("Order dependence: \n\n")
+
+	log.SetIndentLevel(1)
+	for i, seed := range seeds {
+		log.Println(gray(fmt.Sprintf("Order %d: --shuffle --seed %d", i+1, seed)))
+	}
+	log.PrintBlankLine()
+
+	if len(deps) == 0 {
+		log.Println(green("No test cases depend on the order"))
+		log.PrintBlankLine()
+		return
+	}
+
+	for _, dep := range deps {
+		log.Println(yellow(fmt.Sprintf("%s %s", dep.ID, dep.Desc)))
+		log.Println(gray(fmt.Sprintf("  %s", strings.Join(dep.Statuses, ", "))))
+	}
+	log.PrintBlankLine()
+}
+
+// resultStatus returns the status of the test result in the JSON
+// report format.
+func resultStatus(tr *spec.TestResult) string {
+	switch {
+	case tr.NotRun:
+		return JSONStatusNotRun
+	case tr.Skipped:
+		return JSONStatusSkipped
+	case tr.Failed:
+		return JSONStatusFailed
+	}
+	return JSONStatusPassed
+}
@@ -0,0 +1,61 @@
+package reporter
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/summerwind/h2spec/spec"
+)
+
+func TestCompareOrders(t *testing.T) {
+	root := &spec.TestGroup{Key: "http2"}
+	tg := &spec.TestGroup{Key: "http2", Section: "5.1"}
+	root.AddTestGroup(tg)
+
+	var tests []*spec.TestCase
+	for i := 0; i < 4; i++ {
+		tc := &spec.TestCase{Desc: "test"}
+		tg.AddTestCase(tc)
+		tests = append(tests, tc)
+	}
+
+	failure := errors.New("failure")
+	result := func(seq int, err error) *spec.TestResult {
+		return spec.NewTestResult(tests[seq-1], seq, err, time.Duration(0), nil)
+	}
+
+	runs := [][]*spec.TestResult{
+		{result(1, nil), result(2, nil), result(3, failure), result(4, nil)},
+		// The results of the other orders are in the order in which
+		// the test cases were run.
+		{result(4, nil), result(3, failure), result(2, failure), result(1, nil)},
+		{result(2, nil), result(1, nil), result(3, spec.ErrSkipped)},
+	}
+
+	deps := CompareOrders(runs)
+
+	var actual []string
+	for _, dep := range deps {
+		actual = append(actual, dep.ID+" "+strings.Join(dep.Statuses, ","))
+	}
+
+	// The test case 4 was not run in the last order and is ignored.
+	expected := []string{
+		"http2/5.1/2 passed,failed,passed",
+		"http2/5.1/3 failed,failed,skipped",
+	}
+
+	if strings.Join(actual, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expect: %v, got: %v", expected, actual)
+	}
+
+	if deps := CompareOrders(runs[:1]); len(deps) != 0 {
+		t.Errorf("single order - expect: none, got: %d", len(deps))
+	}
+
+	if deps := CompareOrders(nil); deps != nil {
+		t.Errorf("no orders - expect: nil, got: %v", deps)
+	}
+}
@@ -0,0 +1,36 @@
+package reporter
+
+import (
+	"fmt"
+
+	"github.com/summerwind/h2spec/log"
+	"github.com/summerwind/h2spec/spec"
+)
+
+// Preflight outputs the results of the preflight checks with the
+// hints of the failed checks. Failed checks that are not fatal are
+// shown as warnings.
+func Preflight(checks []*spec.PreflightCheck) {
+	log.SetIndentLevel(0)
+	log.Print("Preflight: \n\n")
+
+	log.SetIndentLevel(1)
+	for _, check := range checks {
+		if !check.Failed {
+			log.Println(fmt.Sprintf("%s %s: %s", green("✔"), check.Name, gray(check.Message)))
+			continue
+		}
+
+		if check.Fatal {
+			log.Println(red(fmt.Sprintf("× %s: %s", check.Name, check.Message)))
+		} else {
+			log.Println(yellow(fmt.Sprintf("! %s: %s", check.Name, check.Message)))
+		}
+		if check.Hint != "" {
+			log.Println(yellow(fmt.Sprintf("  -> %s", check.Hint)))
+		}
+	}
+	log.PrintBlankLine()
+
+	log.SetIndentLevel(0)
+}
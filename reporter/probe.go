@@ -0,0 +1,98 @@
+package reporter
+
+import (
+	"fmt"
+
+	"github.com/summerwind/h2spec/log"
+	"github.com/summerwind/h2spec/probe"
+)
+
+// Probe outputs the capabilities of the server as a table.
+func Probe(r *probe.Report) {
+	log.SetIndentLevel(0)
+	log.Print(fmt.Sprintf("Probe: %s\n\n", r.Target))
+
+	log.SetIndentLevel(1)
+	row := func(name, value string) {
+		log.Println(fmt.Sprintf("%-24s %s", name+":", value))
+	}
+
+	if r.TLS != nil {
+		row("TLS", fmt.Sprintf("%s, %s, ALPN: %s", r.TLS.Version, r.TLS.CipherSuite, r.TLS.ALPN))
+	}
+
+	row("SETTINGS", fmt.Sprintf("%d parameters", len(r.Settings)))
+	for _, s := range r.Settings {
+		log.Println(gray(fmt.Sprintf("  %-22s %d", s.Name, s.Value)))
+	}
+
+	row("Header table size", fmt.Sprintf("%d", r.HeaderTableSize))
+	row("Max concurrent streams", optional(r.MaxConcurrentStreams))
+	row("Initial window size", fmt.Sprintf("%d", r.InitialWindowSize))
+	row("Max frame size", fmt.Sprintf("%d", r.MaxFrameSize))
+	row("Max header list size", optional(r.MaxHeaderListSize))
+
+	if r.Push != nil {
+		row("Server push", yesNo(*r.Push))
+	}
+
+	if r.HPACK != nil {
+		h := r.HPACK
+		row("HPACK Huffman coding", fmt.Sprintf("%s (%d of %d strings)", yesNo(h.UsesHuffman()), h.HuffmanStrings, h.HuffmanStrings+h.LiteralStrings))
+		row("HPACK dynamic table", fmt.Sprintf("%s (%d inserts, %d references)", yesNo(h.UsesDynamicTable()), h.DynamicInserts, h.DynamicRefs))
+	}
+
+	if r.PingRTT != nil {
+		row("PING RTT", fmt.Sprintf("%.3f ms", *r.PingRTT*1000))
+	}
+
+	if r.WindowUpdate != nil {
+		wu := r.WindowUpdate
+		row("Window update", wu.Strategy)
+		log.Println(gray(fmt.Sprintf("  %-22s %d bytes in %d DATA frames", "request body", wu.BodyLength, wu.DataFrames)))
+		log.Println(gray(fmt.Sprintf("  %-22s %d updates, %d bytes", "connection", wu.ConnectionUpdates, wu.ConnectionIncrement)))
+		log.Println(gray(fmt.Sprintf("  %-22s %d updates, %d bytes", "stream", wu.StreamUpdates, wu.StreamIncrement)))
+	}
+
+	if r.GoAway != nil {
+		g := r.GoAway
+		goaway := yesNo(g.GoAwaySent)
+		if g.GoAwaySent {
+			goaway = fmt.Sprintf("%s (%s)", goaway, g.ErrorCode)
+		}
+
+		response := "response completed"
+		if !g.ResponseCompleted {
+			response = "response not completed"
+		}
+
+		row("Client GOAWAY", response)
+		log.Println(gray(fmt.Sprintf("  %-22s %s", "GOAWAY sent", goaway)))
+		log.Println(gray(fmt.Sprintf("  %-22s %s", "connection closed", yesNo(g.ConnectionClosed))))
+	}
+
+	if len(r.Errors) > 0 {
+		log.PrintBlankLine()
+		log.Println("Errors:")
+		for _, err := range r.Errors {
+			log.Println(red(fmt.Sprintf("  %s", err)))
+		}
+	}
+
+	log.PrintBlankLine()
+	log.SetIndentLevel(0)
+}
+
+func optional(v *uint32) string {
+	if v == nil {
+		return "unlimited"
+	}
+	return fmt.Sprintf("%d", *v)
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
@@ -3,24 +3,42 @@ package reporter
 import (
 	"fmt"
 
+	"github.com/fatih/color"
 	"github.com/summerwind/h2spec/log"
 	"github.com/summerwind/h2spec/spec"
 )
 
+var (
+	cyan   = color.New(color.FgCyan).SprintFunc()
+	gray   = color.New(color.FgHiBlack).SprintFunc()
+	green  = color.New(color.FgGreen).SprintFunc()
+	red    = color.New(color.FgRed).SprintFunc()
+	yellow = color.New(color.FgYellow).SprintFunc()
+)
+
 // Summary outputs the summary of test result that includes
 // the number of passsed, skipped and failed.
 func Summary(groups []*spec.TestGroup) {
-	var passed, failed, skipped, total int
+	var passed, failed, skipped, flaky, notRun, total int
 
 	for _, tg := range groups {
 		passed += tg.PassedCount
 		failed += tg.FailedCount
 		skipped += tg.SkippedCount
+		flaky += tg.FlakyCount
+		notRun += tg.NotRunCount
 	}
 
 	total = passed + failed + skipped
 	tmp := "%d tests, %d passed, %d skipped, %d failed"
-	log.Println(fmt.Sprintf(tmp, total, passed, skipped, failed))
+	msg := fmt.Sprintf(tmp, total, passed, skipped, failed)
+	if flaky > 0 {
+		msg = fmt.Sprintf("%s (%d flaky)", msg, flaky)
+	}
+	if notRun > 0 {
+		msg = fmt.Sprintf("%s, %d not run", msg, notRun)
+	}
+	log.Println(msg)
 }
 
 // FailedTests outputs the report of failed tests.
@@ -32,6 +50,50 @@ func FailedTests(groups []*spec.TestGroup) {
 	}
 }
 
+// FlakyTests outputs the report of tests that passed after being
+// retried.
+func FlakyTests(groups []*spec.TestGroup) {
+	log.Print("Flaky tests: \n\n")
+
+	for _, tg := range groups {
+		printFlaky(tg)
+	}
+}
+
+func printFlaky(tg *spec.TestGroup) {
+	if tg.FlakyCount == 0 {
+		return
+	}
+
+	level := tg.Level()
+
+	log.SetIndentLevel(level)
+	log.Println(tg.Title())
+	log.SetIndentLevel(level + 1)
+
+	tests := append(tg.Tests, tg.StrictTests...)
+	flaky := false
+
+	for _, tc := range tests {
+		if tc.Result == nil {
+			continue
+		}
+
+		if tc.Result.Flaky {
+			tc.Result.Print()
+			flaky = true
+		}
+	}
+
+	if flaky {
+		log.PrintBlankLine()
+	}
+
+	for _, g := range tg.Groups {
+		printFlaky(g)
+	}
+}
+
 func printFailed(tg *spec.TestGroup) {
 	if tg.FailedCount == 0 {
 		return
@@ -0,0 +1,66 @@
+package reporter
+
+import (
+	"io/ioutil"
+)
+
+// FailedTest represents a test case that failed in a previous run.
+// The ID is empty for the JUnit reports of earlier versions of h2spec
+// that only contain the group and the description of test cases.
+type FailedTest struct {
+	ID    string
+	Group string
+	Desc  string
+}
+
+// LoadFailedTests reads the JSON or JUnit report of the specified
+// path and returns the test cases that failed.
+func LoadFailedTests(filePath string) ([]*FailedTest, error) {
+	buf, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var failed []*FailedTest
+
+	if isJUnitReport(buf) {
+		report, err := LoadJUnitReport(filePath)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, ts := range report.TestSuites {
+			for _, tc := range ts.TestCases {
+				if tc.Failure == nil && tc.Error == nil {
+					continue
+				}
+
+				failed = append(failed, &FailedTest{
+					ID:    tc.Name,
+					Group: tc.Package,
+					Desc:  tc.ClassName,
+				})
+			}
+		}
+
+		return failed, nil
+	}
+
+	report, err := LoadJSONReport(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, tc := range report.TestCases() {
+		if tc.Status != JSONStatusFailed {
+			continue
+		}
+
+		failed = append(failed, &FailedTest{
+			ID:   tc.ID,
+			Desc: tc.Description,
+		})
+	}
+
+	return failed, nil
+}
@@ -0,0 +1,152 @@
+package reporter
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/summerwind/h2spec/config"
+	"github.com/summerwind/h2spec/log"
+	"github.com/summerwind/h2spec/spec"
+)
+
+const (
+	// slowestTestCount is the number of test cases listed as the
+	// slowest tests.
+	slowestTestCount = 10
+	// nearTimeoutRatio is the ratio of the timeout above which the
+	// wait for a frame is considered to be close to the timeout.
+	nearTimeoutRatio = 0.75
+)
+
+// Timing outputs the timing report that includes the slowest tests,
+// the statistics of handshake and the time to the first frame, the
+// wall time of each group and the tests close to the timeout.
+func Timing(groups []*spec.TestGroup, c *config.Config) {
+	var results []*spec.TestResult
+	for _, tg := range groups {
+		results = append(results, tg.TestResults()...)
+	}
+
+	if len(results) == 0 {
+		return
+	}
+
+	log.SetIndentLevel(0)
+	log.Print("Timing: \n\n")
+
+	log.SetIndentLevel(1)
+	log.Println("Slowest tests:")
+	log.SetIndentLevel(2)
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Duration > results[j].Duration
+	})
+
+	for i, tr := range results {
+		if i >= slowestTestCount {
+			break
+		}
+		log.Println(fmt.Sprintf("%.4fs %s %s", tr.Duration.Seconds(), tr.ID(), gray(tr.TestCase.Desc)))
+		log.Println(gray(fmt.Sprintf("        handshake: %.4fs, first frame: %.4fs, max wait: %.4fs", tr.Handshake.Seconds(), tr.FirstFrame.Seconds(), tr.MaxWait.Seconds())))
+	}
+	log.PrintBlankLine()
+
+	log.SetIndentLevel(1)
+	log.Println(fmt.Sprintf("Handshake:   %s", durationStats(results, func(tr *spec.TestResult) time.Duration {
+		return tr.Handshake
+	})))
+	log.Println(fmt.Sprintf("First frame: %s", durationStats(results, func(tr *spec.TestResult) time.Duration {
+		return tr.FirstFrame
+	})))
+	log.PrintBlankLine()
+
+	log.Println("Groups:")
+	for _, tg := range groups {
+		printGroupTiming(tg, 0)
+	}
+	log.PrintBlankLine()
+
+	near := nearTimeout(results, c)
+
+	log.SetIndentLevel(1)
+	log.Println(fmt.Sprintf("Close to the timeout (waited %.0f%% of the timeout of the test or more for a frame):", nearTimeoutRatio*100))
+	log.SetIndentLevel(2)
+	if len(near) == 0 {
+		log.Println(gray("None"))
+	}
+	for _, tr := range near {
+		limit := testTimeout(tr, c)
+		log.Println(yellow(fmt.Sprintf("%.4fs of %.4fs %s %s", tr.MaxWait.Seconds(), limit.Seconds(), tr.ID(), tr.TestCase.Desc)))
+	}
+	log.PrintBlankLine()
+}
+
+// nearTimeout returns the results whose longest wait for a frame is
+// close to the timeout of their test case.
+func nearTimeout(results []*spec.TestResult, c *config.Config) []*spec.TestResult {
+	near := []*spec.TestResult{}
+	for _, tr := range results {
+		limit := testTimeout(tr, c)
+		if tr.MaxWait >= time.Duration(float64(limit)*nearTimeoutRatio) {
+			near = append(near, tr)
+		}
+	}
+
+	return near
+}
+
+// testTimeout returns the timeout that the test case of the result was
+// run with. Test cases may override the timeout of the run, relative
+// to the default timeout.
+func testTimeout(tr *spec.TestResult, c *config.Config) time.Duration {
+	return c.WithTimeout(tr.TestCase.Timeout).Timeout
+}
+
+// printGroupTiming outputs the wall time of the group and its direct
+// sub groups.
+func printGroupTiming(tg *spec.TestGroup, depth int) {
+	if tg.Duration == 0 {
+		return
+	}
+
+	log.SetIndentLevel(depth + 2)
+	log.Println(fmt.Sprintf("%.4fs %s", tg.Duration.Seconds(), gray(tg.Title())))
+
+	if depth > 0 {
+		return
+	}
+
+	for _, g := range tg.Groups {
+		printGroupTiming(g, depth+1)
+	}
+}
+
+// durationStats returns the string of average and maximum of the
+// duration selected by fn. Results without the duration, such as
+// skipped test cases or test cases that never handshake, are not
+// included.
+func durationStats(results []*spec.TestResult, fn func(*spec.TestResult) time.Duration) string {
+	var total, max time.Duration
+	count := 0
+
+	for _, tr := range results {
+		d := fn(tr)
+		if d == 0 {
+			continue
+		}
+
+		count++
+		total += d
+		if d > max {
+			max = d
+		}
+	}
+
+	if count == 0 {
+		return gray("None")
+	}
+
+	avg := total / time.Duration(count)
+	return fmt.Sprintf("avg %.4fs, max %.4fs (%d tests)", avg.Seconds(), max.Seconds(), count)
+}
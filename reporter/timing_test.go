@@ -0,0 +1,41 @@
+package reporter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/summerwind/h2spec/config"
+	"github.com/summerwind/h2spec/spec"
+)
+
+func TestNearTimeout(t *testing.T) {
+	tests := []struct {
+		timeout     time.Duration
+		testTimeout time.Duration
+		maxWait     time.Duration
+		near        bool
+	}{
+		{timeout: 2 * time.Second, maxWait: 1400 * time.Millisecond, near: false},
+		{timeout: 2 * time.Second, maxWait: 1500 * time.Millisecond, near: true},
+		// The timeout of the test case is relative to the default
+		// timeout and scales with the timeout of the run.
+		{timeout: 2 * time.Second, testTimeout: time.Second, maxWait: 750 * time.Millisecond, near: true},
+		{timeout: 10 * time.Second, testTimeout: time.Second, maxWait: 750 * time.Millisecond, near: false},
+		{timeout: 10 * time.Second, testTimeout: time.Second, maxWait: 3750 * time.Millisecond, near: true},
+		{timeout: 10 * time.Second, testTimeout: 5 * time.Second, maxWait: 10 * time.Second, near: false},
+		{timeout: 10 * time.Second, testTimeout: 5 * time.Second, maxWait: 20 * time.Second, near: true},
+	}
+
+	for i, tt := range tests {
+		c := &config.Config{Timeout: tt.timeout}
+		tr := &spec.TestResult{
+			TestCase: &spec.TestCase{Timeout: tt.testTimeout},
+			MaxWait:  tt.maxWait,
+		}
+
+		near := len(nearTimeout([]*spec.TestResult{tr}, c)) == 1
+		if near != tt.near {
+			t.Errorf("#%d - expect: %v, got: %v", i, tt.near, near)
+		}
+	}
+}
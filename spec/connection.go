@@ -25,6 +25,9 @@ const (
 	DefaultWindowSize = 65535
 	// DefaultFrameSize is the value of default frame size.
 	DefaultFrameSize = 16384
+	// MaxRecordedEvents is the maximum number of events recorded for
+	// the test result.
+	MaxRecordedEvents = 100
 )
 
 // Conn represent a HTTP/2 connection.
@@ -50,40 +53,96 @@ type Conn struct {
 	debugFramerBuf *bytes.Buffer
 
 	server bool
+
+	// handshakeSettings are sent in the SETTINGS frame of the
+	// handshake. These are the initial window size and the settings of
+	// the configuration.
+	handshakeSettings []http2.Setting
+
+	// Timing of the connection. The time to the first frame is
+	// measured from the first request to the first frame of a stream
+	// or GOAWAY frame, so that the frames the server sends for the
+	// handshake are not counted. The time of the first request is
+	// zero until a HEADERS frame is sent by the client.
+	requestTime        time.Time
+	handshakeDuration  time.Duration
+	firstFrameDuration time.Duration
+	maxWaitDuration    time.Duration
+
+	// events is the string representation of the events returned by
+	// WaitEvent.
+	events []string
+
+	// body is the request body that is being sent by WriteBody.
+	body *bodyWriter
 }
 
 // Dial connects to the server based on configuration.
 func Dial(c *config.Config) (*Conn, error) {
+	tlsConfig, err := c.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return DialWithTLSConfig(c, tlsConfig)
+}
+
+// DialWithTLSConfig connects to the server based on configuration
+// using the specified TLS configuration instead of the one of the
+// configuration. This is used by tests that need a specific TLS
+// handshake.
+func DialWithTLSConfig(c *config.Config, tlsConfig *tls.Config) (*Conn, error) {
 	var baseConn net.Conn
 	var err error
 
-	if c.TLS {
-		dialer := &net.Dialer{}
-		dialer.Timeout = c.Timeout
+	if c.Proxy != "" {
+		baseConn, err = dialProxy(c.Dialer(), c.Network(), c.Proxy, c.Addr())
+	} else {
+		baseConn, err = c.Dialer().Dial(c.Network(), c.Addr())
+	}
+	if err != nil {
+		return nil, err
+	}
 
-		tlsConfig, err := c.TLSConfig()
+	// The PROXY protocol header precedes both TLS and HTTP/2.
+	if c.ProxyProtocol != "" {
+		err = sendProxyHeader(c, baseConn)
 		if err != nil {
+			baseConn.Close()
 			return nil, err
 		}
+	}
 
-		tlsConn, err := tls.DialWithDialer(dialer, "tcp", c.Addr(), tlsConfig)
+	if c.TLS {
+		tlsConfig = tlsConfig.Clone()
+		if tlsConfig.ServerName == "" {
+			tlsConfig.ServerName = c.Host
+		}
+
+		tlsConn := tls.Client(baseConn, tlsConfig)
+		tlsConn.SetDeadline(time.Now().Add(c.Timeout))
+
+		err = tlsConn.Handshake()
 		if err != nil {
+			baseConn.Close()
 			return nil, err
 		}
 
+		tlsConn.SetDeadline(time.Time{})
+
 		cs := tlsConn.ConnectionState()
 		if !cs.NegotiatedProtocolIsMutual {
-			return nil, errors.New("Protocol negotiation failed")
+			baseConn.Close()
+			return nil, ErrProtocolNegotiation
 		}
 
 		baseConn = tlsConn
-	} else {
-		baseConn, err = net.DialTimeout("tcp", c.Addr(), c.Timeout)
-		if err != nil {
-			return nil, err
-		}
 	}
 
+	// The write mode applies to the data of HTTP/2 rather than to
+	// the TLS records.
+	baseConn = wrapWriteMode(c, baseConn)
+
 	settings := map[http2.SettingID]uint32{}
 
 	framer := http2.NewFramer(baseConn, baseConn)
@@ -95,6 +154,18 @@ func Dial(c *config.Config) (*Conn, error) {
 
 	decoder := hpack.NewDecoder(4096, func(f hpack.HeaderField) {})
 
+	// The connection must accept what it advertises in the SETTINGS
+	// frame of the handshake.
+	clientSettings := c.ClientSettings()
+	for _, s := range clientSettings {
+		switch s.ID {
+		case http2.SettingHeaderTableSize:
+			decoder.SetAllowedMaxDynamicTableSize(s.Val)
+		case http2.SettingMaxFrameSize:
+			framer.SetMaxReadFrameSize(s.Val)
+		}
+	}
+
 	conn := Conn{
 		Conn:     baseConn,
 		Settings: settings,
@@ -111,6 +182,11 @@ func Dial(c *config.Config) (*Conn, error) {
 		decoder:    decoder,
 
 		server: false,
+
+		handshakeSettings: append([]http2.Setting{{
+			ID:  http2.SettingInitialWindowSize,
+			Val: DefaultWindowSize,
+		}}, clientSettings...),
 	}
 
 	if conn.Verbose {
@@ -151,6 +227,9 @@ func Accept(c *config.Config, baseConn net.Conn) (*Conn, error) {
 		decoder:    decoder,
 
 		server: true,
+
+		// The client starts with the request.
+		requestTime: time.Now(),
 	}
 
 	if conn.Verbose {
@@ -165,11 +244,26 @@ func Accept(c *config.Config, baseConn net.Conn) (*Conn, error) {
 
 // Handshake performs HTTP/2 handshake with the server.
 func (conn *Conn) Handshake() error {
+	var err error
+
+	start := time.Now()
 	if conn.server {
-		return conn.handshakeAsServer()
+		err = conn.handshakeAsServer()
 	} else {
-		return conn.handshakeAsClient()
+		err = conn.handshakeAsClient()
 	}
+
+	conn.handshakeDuration = time.Since(start)
+
+	return err
+}
+
+// HandshakeWithSettings performs HTTP/2 handshake with the server,
+// sending only the specified settings in the SETTINGS frame so that
+// the server uses the initial values of the others.
+func (conn *Conn) HandshakeWithSettings(settings ...http2.Setting) error {
+	conn.handshakeSettings = settings
+	return conn.Handshake()
 }
 
 // MaxFrameSize returns value of Handshake performs HTTP/2 handshake
@@ -238,9 +332,19 @@ func (conn *Conn) WriteHeaders(p http2.HeadersFrameParam) error {
 		conn.logFrameSend()
 	}
 
+	conn.startRequest()
 	return conn.framer.WriteHeaders(p)
 }
 
+// WriteBody sends the request body of the stream in DATA frames that
+// fit in the flow control windows of the server. The last DATA frame
+// ends the stream. The rest of the body is sent by WaitEvent when the
+// server opens the windows with WINDOW_UPDATE frames.
+func (conn *Conn) WriteBody(streamID uint32, body []byte) error {
+	conn.body = newBodyWriter(conn, streamID, body)
+	return conn.body.write()
+}
+
 // WritePriority sends a PRIORITY frame.
 func (conn *Conn) WritePriority(streamID uint32, p http2.PriorityParam) error {
 	if conn.Verbose {
@@ -337,6 +441,9 @@ func (conn *Conn) WriteRawFrame(t http2.FrameType, flags http2.Flags, streamID u
 		conn.logFrameSend()
 	}
 
+	if t == http2.FrameHeaders {
+		conn.startRequest()
+	}
 	return conn.framer.WriteRawFrame(t, flags, streamID, payload)
 }
 
@@ -348,15 +455,27 @@ func (conn *Conn) WriteSuccessResponse(streamID uint32, c *config.Config) {
 		BlockFragment: conn.EncodeHeaders(CommonRespHeaders(c)),
 	}
 	conn.WriteHeaders(hp)
-	conn.WriteData(streamID, true, []byte("success"))
+
+	body := []byte("success")
+	if len(c.Body) > 0 {
+		body = c.Body
+	}
+	conn.WriteBody(streamID, body)
 }
 
 // WaitEvent returns a event occured on connection. This function is
 // used to wait the next event on the connection.
 func (conn *Conn) WaitEvent() Event {
+	ev := conn.waitEvent()
+	conn.recordEvent(ev)
+	return ev
+}
+
+func (conn *Conn) waitEvent() Event {
 	var ev Event
 
-	rd := time.Now().Add(conn.Timeout)
+	start := time.Now()
+	rd := start.Add(conn.Timeout)
 	conn.SetReadDeadline(rd)
 
 	f, err := conn.framer.ReadFrame()
@@ -407,6 +526,8 @@ func (conn *Conn) WaitEvent() Event {
 		return ev
 	}
 
+	conn.updateTiming(start, f)
+
 	_, ok := f.(*http2.DataFrame)
 	if ok {
 		conn.updateWindowSize(f)
@@ -415,6 +536,18 @@ func (conn *Conn) WaitEvent() Event {
 	ev = getEventByFrame(f)
 	conn.vlog(ev, false)
 
+	// The rest of the request body is sent when the server opens the
+	// flow control windows.
+	wu, ok := ev.(WindowUpdateFrameEvent)
+	if ok && conn.body != nil && !conn.body.done {
+		conn.body.update(wu)
+		err = conn.body.write()
+		if err != nil {
+			ev = ErrorEvent{err}
+			conn.vlog(ev, false)
+		}
+	}
+
 	return ev
 }
 
@@ -514,6 +647,41 @@ func (conn *Conn) updateWindowSize(f http2.Frame) {
 	}
 }
 
+// recordEvent records the event returned by WaitEvent.
+func (conn *Conn) recordEvent(ev Event) {
+	if len(conn.events) < MaxRecordedEvents {
+		conn.events = append(conn.events, ev.String())
+	}
+}
+
+// startRequest records the time of the first request of the client.
+func (conn *Conn) startRequest() {
+	if !conn.server && conn.requestTime.IsZero() {
+		conn.requestTime = time.Now()
+	}
+}
+
+// updateTiming records the time spent waiting for the frame that
+// arrived just now. The time to the first frame is recorded for the
+// first frame of a stream or GOAWAY frame after the first request.
+func (conn *Conn) updateTiming(waitStart time.Time, f http2.Frame) {
+	now := time.Now()
+
+	wait := now.Sub(waitStart)
+	if wait > conn.maxWaitDuration {
+		conn.maxWaitDuration = wait
+	}
+
+	if conn.firstFrameDuration != 0 || conn.requestTime.IsZero() {
+		return
+	}
+
+	_, goAway := f.(*http2.GoAwayFrame)
+	if f.Header().StreamID != 0 || goAway {
+		conn.firstFrameDuration = now.Sub(conn.requestTime)
+	}
+}
+
 // logFrameSend writes a log of the frame to be sent.
 func (conn *Conn) logFrameSend() {
 	f, err := conn.debugFramer.ReadFrame()
@@ -584,11 +752,7 @@ func (conn *Conn) handshakeAsClient() error {
 		local := false
 		remote := false
 
-		setting := http2.Setting{
-			ID:  http2.SettingInitialWindowSize,
-			Val: DefaultWindowSize,
-		}
-		conn.WriteSettings(setting)
+		conn.WriteSettings(conn.handshakeSettings...)
 
 		for !(local && remote) {
 			f, err := conn.framer.ReadFrame()
@@ -704,3 +868,47 @@ func (conn *Conn) readBytes(size int) ([]byte, error) {
 	}
 	return buffer, nil
 }
+
+// TLSInfo represents the parameters negotiated in the TLS handshake.
+type TLSInfo struct {
+	Version     string
+	CipherSuite string
+	ALPN        string
+	// Resumed is true if the session was resumed from a previous
+	// connection.
+	Resumed bool
+}
+
+// TLSInfo returns the parameters negotiated in the TLS handshake, or
+// nil if the connection does not use TLS.
+func (conn *Conn) TLSInfo() *TLSInfo {
+	baseConn := conn.Conn
+	if wc, ok := baseConn.(*writeModeConn); ok {
+		baseConn = wc.Conn
+	}
+
+	tlsConn, ok := baseConn.(*tls.Conn)
+	if !ok {
+		return nil
+	}
+
+	cs := tlsConn.ConnectionState()
+	return &TLSInfo{
+		Version:     tls.VersionName(cs.Version),
+		CipherSuite: tls.CipherSuiteName(cs.CipherSuite),
+		ALPN:        cs.NegotiatedProtocol,
+		Resumed:     cs.DidResume,
+	}
+}
+
+// NegotiateTLS connects to the server and returns the parameters
+// negotiated in the TLS handshake.
+func NegotiateTLS(c *config.Config) (*TLSInfo, error) {
+	conn, err := Dial(c)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return conn.TLSInfo(), nil
+}
@@ -0,0 +1,137 @@
+package spec
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/net/http2"
+
+	"github.com/summerwind/h2spec/config"
+	"github.com/summerwind/h2spec/log"
+)
+
+// ErrServerCrashed is used when the server stopped responding after
+// a test case that passed.
+var ErrServerCrashed = errors.New("The server stopped responding after the test case")
+
+// CheckHealth verifies whether the server is alive by sending a
+// request and a PING frame on a new connection.
+func CheckHealth(c *config.Config) error {
+	conn, err := Dial(c)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	err = conn.Handshake()
+	if err != nil {
+		return err
+	}
+
+	headers := CommonHeaders(c)
+	hp := http2.HeadersFrameParam{
+		StreamID:      1,
+		EndStream:     true,
+		EndHeaders:    true,
+		BlockFragment: conn.EncodeHeaders(headers),
+	}
+	err = conn.WriteHeaders(hp)
+	if err != nil {
+		return err
+	}
+
+	data := [8]byte{'h', '2', 's', 'p', 'e', 'c'}
+	err = conn.WritePing(false, data)
+	if err != nil {
+		return err
+	}
+
+	responded, acked := false, false
+	for !(responded && acked) {
+		ev := conn.WaitEvent()
+
+		switch event := ev.(type) {
+		case HeadersFrameEvent:
+			responded = responded || event.Header().StreamID == 1
+		case PingFrameEvent:
+			acked = acked || (event.IsAck() && event.Data == data)
+		case ErrorEvent, ConnectionClosedEvent, GoAwayFrameEvent, TimeoutEvent:
+			return errors.New(ev.String())
+		}
+	}
+
+	return nil
+}
+
+// waitForServer checks the health of the server. If the check
+// fails, it is retried up to the configured number of times until the
+// server responds again. crashed is true if the first check failed,
+// and the error of the last check is returned if the server did not
+// recover.
+func waitForServer(c *config.Config) (crashed bool, err error) {
+	err = CheckHealth(c)
+	if err == nil {
+		return false, nil
+	}
+
+	log.ResetLine()
+	for attempt := 1; attempt <= c.HealthCheckRetries; attempt++ {
+		log.Println(yellow(fmt.Sprintf("     server is not responding, retrying health check (attempt %d of %d)", attempt, c.HealthCheckRetries)))
+		time.Sleep(c.HealthCheckInterval)
+
+		err = CheckHealth(c)
+		if err == nil {
+			return true, nil
+		}
+	}
+
+	return true, fmt.Errorf("Server did not recover: %v", err)
+}
+
+// runAfterRecovery handles the error of connecting to the server for
+// the test case. If the server stopped responding after the previous
+// test case passed its health check, the previous test case is marked
+// as crashed and the test case is run again once the server recovered.
+// Otherwise the error is returned.
+func (tc *TestCase) runAfterRecovery(base, c *config.Config, state *RunState, seq int, dialErr error) (*TestResult, error) {
+	crashed, err := waitForServer(base)
+	if crashed && state.last != nil && state.last.Result != nil {
+		markCrashedBefore(state.last, tc)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	if !crashed {
+		return nil, dialErr
+	}
+
+	return tc.run(c, seq)
+}
+
+// markCrashedBefore marks the result of prev, which was run right
+// before tc, as crashed and updates the counters of the groups that
+// have already counted it. The groups that contain tc are still
+// running and do not include the counters of their sub groups yet.
+func markCrashedBefore(prev, tc *TestCase) {
+	tr := prev.Result
+
+	var groups []*TestGroup
+	for g := prev.Parent; g != nil; g = g.Parent {
+		groups = append(groups, g)
+		if g.contains(tc.Parent) {
+			break
+		}
+	}
+
+	for _, g := range groups {
+		g.count(tr, -1)
+	}
+	tr.markCrashed()
+	for _, g := range groups {
+		g.count(tr, 1)
+	}
+
+	log.Println(yellow(fmt.Sprintf("     server stopped responding after %s", tr.ID())))
+}
@@ -0,0 +1,103 @@
+package spec
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/summerwind/h2spec/config"
+)
+
+func TestMarkCrashedBefore(t *testing.T) {
+	root := &TestGroup{Key: "http2"}
+	g1 := &TestGroup{Key: "http2", Section: "1"}
+	g2 := &TestGroup{Key: "http2", Section: "2"}
+	root.AddTestGroup(g1)
+	root.AddTestGroup(g2)
+
+	prev := &TestCase{Desc: "prev"}
+	next := &TestCase{Desc: "next"}
+	g1.AddTestCase(prev)
+	g2.AddTestCase(next)
+
+	// The previous group has been counted by the root group, which is
+	// still running the next group.
+	prev.Result = NewTestResult(prev, 1, nil, time.Duration(0), nil)
+	g1.count(prev.Result, 1)
+	root.count(prev.Result, 1)
+
+	markCrashedBefore(prev, next)
+
+	if !prev.Result.CrashedServer || !prev.Result.Failed {
+		t.Errorf("expect the previous result to be crashed, got: %+v", prev.Result)
+	}
+
+	for _, g := range []*TestGroup{g1, root} {
+		if g.PassedCount != 0 || g.FailedCount != 1 {
+			t.Errorf("%s: expect: 0 passed, 1 failed, got: %d passed, %d failed", g.ID(), g.PassedCount, g.FailedCount)
+		}
+	}
+
+	if g2.FailedCount != 0 {
+		t.Errorf("%s: expect: 0 failed, got: %d failed", g2.ID(), g2.FailedCount)
+	}
+}
+
+func TestRunStoppedByError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	tests := []struct {
+		name        string
+		healthCheck bool
+		err         string
+	}{
+		{name: "connection error", healthCheck: false, err: "connection refused"},
+		{name: "no recovery", healthCheck: true, err: "Server did not recover"},
+	}
+
+	for _, tt := range tests {
+		c := &config.Config{
+			Host:        "127.0.0.1",
+			Port:        port,
+			Timeout:     time.Second,
+			HealthCheck: tt.healthCheck,
+		}
+
+		root := &TestGroup{Key: "http2"}
+		g1 := &TestGroup{Key: "http2", Section: "1"}
+		g2 := &TestGroup{Key: "http2", Section: "2"}
+		root.AddTestGroup(g1)
+		root.AddTestGroup(g2)
+
+		run := func(c *config.Config, conn *Conn) error {
+			return nil
+		}
+		g1.AddTestCase(&TestCase{Desc: "1", Run: run})
+		g1.AddTestCase(&TestCase{Desc: "2", Run: run})
+		g2.AddTestCase(&TestCase{Desc: "3", Run: run})
+
+		state := &RunState{}
+		root.Test(c, state)
+
+		if state.Err == nil || !strings.Contains(state.Err.Error(), tt.err) {
+			t.Errorf("%s - expect: %s, got: %v", tt.name, tt.err, state.Err)
+		}
+
+		// The test cases are reported as not run, including the one
+		// that stopped the run.
+		if root.NotRunCount != 3 {
+			t.Errorf("%s - expect: 3 not run, got: %d", tt.name, root.NotRunCount)
+		}
+		for _, tc := range append(g1.Tests, g2.Tests...) {
+			if tc.Result == nil || !tc.Result.NotRun {
+				t.Errorf("%s - %s: expect: not run, got: %+v", tt.name, tc.Desc, tc.Result)
+			}
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package spec
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+)
+
+const (
+	HookBeforeEach = "before-each"
+	HookAfterEach  = "after-each"
+)
+
+// hookTimeout is the maximum duration of a hook command. The command
+// is killed when it is exceeded so that a hanging hook does not block
+// the run.
+var hookTimeout = 60 * time.Second
+
+// HookOutput represents the output of a hook command run for a test
+// case.
+type HookOutput struct {
+	Name   string
+	Output string
+	// Error is the error of the command, such as a non-zero exit
+	// status. It does not change the result of the test case.
+	Error error
+}
+
+// runHook runs the command with the shell. The ID of the test case,
+// the source address of its connection and its result are passed in
+// the environment variables H2SPEC_TEST_ID, H2SPEC_SOURCE_ADDR and
+// H2SPEC_RESULT. The last two are empty for the before-each hook.
+func runHook(name, command, id, addr, result string) *HookOutput {
+	ctx, cancel := context.WithTimeout(context.Background(), hookTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	// The output of processes started by the command that are still
+	// running after it is killed is not waited for.
+	cmd.WaitDelay = time.Second
+	cmd.Env = append(os.Environ(),
+		fmt.Sprintf("H2SPEC_TEST_ID=%s", id),
+		fmt.Sprintf("H2SPEC_SOURCE_ADDR=%s", addr),
+		fmt.Sprintf("H2SPEC_RESULT=%s", result),
+	)
+
+	out, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("Timed out after %v", hookTimeout)
+	}
+
+	return &HookOutput{
+		Name:   name,
+		Output: string(out),
+		Error:  err,
+	}
+}
+
+// hookResult returns the result of the test case passed to the
+// after-each hook.
+func hookResult(tr *TestResult) string {
+	switch {
+	case tr.Skipped:
+		return "skipped"
+	case tr.Failed:
+		return "failed"
+	default:
+		return "passed"
+	}
+}
@@ -0,0 +1,103 @@
+package spec
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/summerwind/h2spec/config"
+)
+
+const hookEnv = `echo "$H2SPEC_TEST_ID|$H2SPEC_SOURCE_ADDR|$H2SPEC_RESULT"`
+
+func TestRunHook(t *testing.T) {
+	h := runHook(HookAfterEach, hookEnv, "http2/1/1", "127.0.0.1:1234", "failed")
+	if h.Name != HookAfterEach {
+		t.Errorf("name - expect: %s, got: %s", HookAfterEach, h.Name)
+	}
+	if h.Output != "http2/1/1|127.0.0.1:1234|failed\n" {
+		t.Errorf("output - expect: http2/1/1|127.0.0.1:1234|failed, got: %q", h.Output)
+	}
+	if h.Error != nil {
+		t.Errorf("error - expect: nil, got: %v", h.Error)
+	}
+
+	h = runHook(HookBeforeEach, "echo error; exit 1", "http2/1/1", "", "")
+	if h.Output != "error\n" || h.Error == nil {
+		t.Errorf("exit status - expect: error output and error, got: %q, %v", h.Output, h.Error)
+	}
+}
+
+func TestRunHookTimeout(t *testing.T) {
+	timeout := hookTimeout
+	hookTimeout = 100 * time.Millisecond
+	defer func() { hookTimeout = timeout }()
+
+	start := time.Now()
+	h := runHook(HookBeforeEach, "echo start; sleep 10", "http2/1/1", "", "")
+
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("duration - expect: less than 5s, got: %v", d)
+	}
+	if h.Error == nil || !strings.Contains(h.Error.Error(), "Timed out") {
+		t.Errorf("error - expect: timeout, got: %v", h.Error)
+	}
+	if h.Output != "start\n" {
+		t.Errorf("output - expect: start, got: %q", h.Output)
+	}
+}
+
+// TestHooksOfTestCase checks the output of the hooks attached to the
+// result of a test case.
+func TestHooksOfTestCase(t *testing.T) {
+	host, port, _ := net.SplitHostPort(startEchoServer(t))
+	portNum, _ := strconv.Atoi(port)
+
+	c := &config.Config{
+		Host:       host,
+		Port:       portNum,
+		Timeout:    time.Second,
+		BeforeEach: hookEnv,
+		AfterEach:  hookEnv,
+	}
+
+	root := &TestGroup{Key: "http2"}
+	tg := &TestGroup{Key: "http2", Section: "1"}
+	root.AddTestGroup(tg)
+
+	tc := &TestCase{
+		Desc: "test",
+		Run: func(c *config.Config, conn *Conn) error {
+			return nil
+		},
+	}
+	tg.AddTestCase(tc)
+
+	state := &RunState{}
+	tc.Test(c, state, 1)
+	if state.Err != nil {
+		t.Fatal(state.Err)
+	}
+
+	tr := tc.Result
+	if len(tr.Hooks) != 2 {
+		t.Fatalf("hooks - expect: 2, got: %d", len(tr.Hooks))
+	}
+
+	expected := []struct {
+		name   string
+		output string
+	}{
+		{name: HookBeforeEach, output: "http2/1/1||\n"},
+		{name: HookAfterEach, output: "http2/1/1|" + tr.SourceAddr.String() + "|passed\n"},
+	}
+
+	for i, e := range expected {
+		h := tr.Hooks[i]
+		if h.Name != e.name || h.Output != e.output {
+			t.Errorf("#%d - expect: %s %q, got: %s %q", i, e.name, e.output, h.Name, h.Output)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package spec
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/http2"
+
+	"github.com/summerwind/h2spec/config"
+)
+
+// PreflightCheck represents the result of a check of the target run
+// before the test cases.
+type PreflightCheck struct {
+	Name    string
+	Message string
+	// Hint describes how to fix the setup if the check failed.
+	Hint   string
+	Failed bool
+	// Fatal is true if the test cases cannot run when the check
+	// failed. The failure of other checks only affects some of the
+	// test cases.
+	Fatal bool
+}
+
+// Preflight checks that the target is set up for the test cases. The
+// checks that depend on a previous check are not run if it failed.
+func Preflight(c *config.Config) []*PreflightCheck {
+	var checks []*PreflightCheck
+
+	conn, check := preflightConnect(c)
+	checks = append(checks, check)
+	if conn == nil {
+		return checks
+	}
+
+	check = preflightHandshake(c, conn)
+	conn.Close()
+	checks = append(checks, check)
+	if check.Failed {
+		return checks
+	}
+
+	checks = append(checks, preflightRequest(c, "GET", nil)...)
+
+	// The POST request has the body of the configuration, if any.
+	body := []byte("h2spec")
+	if c.Body != nil {
+		body = c.Body
+	}
+	checks = append(checks, preflightRequest(c, "POST", body)...)
+
+	return checks
+}
+
+func preflightConnect(c *config.Config) (*Conn, *PreflightCheck) {
+	check := &PreflightCheck{Name: "Connection", Fatal: true}
+
+	conn, err := Dial(c)
+	if err != nil {
+		check.Failed = true
+		check.Message = fmt.Sprintf("Unable to connect to %s: %v", c.Addr(), err)
+
+		var proxyErr *ProxyError
+		var certErr x509.UnknownAuthorityError
+		var hostErr x509.HostnameError
+		var recordErr tls.RecordHeaderError
+		switch {
+		case errors.As(err, &proxyErr):
+			check.Message = fmt.Sprintf("Proxy setup error: unable to connect to %s through %s: %v", c.Addr(), proxyErr.Proxy, proxyErr.Err)
+			check.Hint = "Check that the proxy of --proxy is running, that its credentials are valid and that it allows connections to --host and --port."
+		case errors.Is(err, syscall.ECONNREFUSED):
+			check.Hint = "Check that the server is running and listening on --host and --port."
+		case err == ErrProtocolNegotiation:
+			check.Hint = "The server did not select h2 with ALPN. Check that HTTP/2 is enabled on the TLS listener."
+		case errors.As(err, &recordErr):
+			check.Hint = "The server did not respond with TLS. Check that --port is the TLS port, or remove --tls."
+		case errors.As(err, &certErr), errors.As(err, &hostErr):
+			check.Hint = "Specify the CA certificate with --ca-file, or skip verification with --insecure."
+		}
+
+		return nil, check
+	}
+
+	info := conn.TLSInfo()
+	if info != nil {
+		check.Message = fmt.Sprintf("Connected to %s (%s, %s, ALPN: %s)", c.Addr(), info.Version, info.CipherSuite, info.ALPN)
+	} else {
+		check.Message = fmt.Sprintf("Connected to %s", c.Addr())
+	}
+
+	return conn, check
+}
+
+func preflightHandshake(c *config.Config, conn *Conn) *PreflightCheck {
+	check := &PreflightCheck{Name: "Preface and SETTINGS", Fatal: true}
+
+	err := conn.Handshake()
+	if err != nil {
+		check.Failed = true
+		check.Message = fmt.Sprintf("HTTP/2 handshake failed: %v", err)
+
+		if sniffHTTP1(c) {
+			check.Hint = "The server responded with data that is not an HTTP/2 frame, such as an HTTP/1.1 response. Check that HTTP/2 is enabled, or use --tls if the server only supports HTTP/2 over TLS."
+		} else {
+			check.Hint = "The server did not respond to the connection preface with a SETTINGS frame. Check that HTTP/2 with prior knowledge is supported."
+		}
+
+		return check
+	}
+
+	check.Message = fmt.Sprintf("Received SETTINGS frame with %d settings", len(conn.Settings))
+
+	return check
+}
+
+// sniffHTTP1 sends the connection preface on a new connection and
+// returns true if the response does not start with a SETTINGS frame
+// header.
+func sniffHTTP1(c *config.Config) bool {
+	conn, err := Dial(c)
+	if err != nil {
+		return false
+	}
+	defer conn.Close()
+
+	fmt.Fprintf(conn, "PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n")
+
+	conn.SetReadDeadline(time.Now().Add(c.Timeout))
+	buf := make([]byte, 9)
+	_, err = io.ReadFull(conn, buf)
+	if err != nil {
+		return false
+	}
+
+	return http2.FrameType(buf[3]) != http2.FrameSettings
+}
+
+// preflightRequest sends a request with the method to the target path
+// and checks the status of the response. The length of the response
+// body is checked for GET requests. Only the check of the GET request
+// is fatal since the other checks affect some test cases only.
+func preflightRequest(c *config.Config, method string, body []byte) []*PreflightCheck {
+	check := &PreflightCheck{
+		Name:  fmt.Sprintf("%s %s", method, c.Path),
+		Fatal: method == "GET",
+	}
+
+	headers := CommonHeaders(c)
+	headers[0].Value = method
+
+	res, err := SendRequest(c, headers, body)
+	if err != nil {
+		check.Failed = true
+		check.Message = fmt.Sprintf("No response: %v", err)
+		check.Hint = "Check that the server responds to requests for --path."
+		return []*PreflightCheck{check}
+	}
+
+	check.Message = fmt.Sprintf("Received status %s", res.Status)
+
+	status, _ := strconv.Atoi(res.Status)
+	if status < 200 || status >= 400 {
+		check.Failed = true
+
+		switch {
+		case method == "POST":
+			check.Hint = "Test cases that send a request body may fail. Map them to a path that accepts POST requests with targets in the configuration file."
+		case status == 404:
+			check.Hint = "Use --path to specify a path that exists on the server."
+		default:
+			check.Hint = "Use --path to specify a path that returns a successful response."
+		}
+	}
+
+	if method != "GET" || check.Failed {
+		return []*PreflightCheck{check}
+	}
+
+	bodyCheck := &PreflightCheck{
+		Name:    "Response body",
+		Message: fmt.Sprintf("Received %d bytes", res.DataLength),
+	}
+
+	if res.DataLength == 0 {
+		bodyCheck.Failed = true
+		bodyCheck.Hint = "Flow control test cases are skipped without a response body. Use --path to specify a path that returns a body."
+	}
+
+	return []*PreflightCheck{check, bodyCheck}
+}
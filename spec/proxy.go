@@ -0,0 +1,247 @@
+package spec
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// ProxyError represents an error that occurred while establishing a
+// tunnel through the proxy. It is not a failure of the target server.
+type ProxyError struct {
+	Proxy string
+	Err   error
+}
+
+// Error returns a string containing the reason of the error.
+func (e *ProxyError) Error() string {
+	return fmt.Sprintf("Proxy error (%s): %v", e.Proxy, e.Err)
+}
+
+// dialProxy connects to the address through the proxy of the
+// specified URL using the dialer. Supported schemes are "http" for
+// HTTP/1.1 CONNECT and "socks5".
+func dialProxy(d *net.Dialer, network, proxy, addr string) (net.Conn, error) {
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return nil, &ProxyError{proxy, err}
+	}
+
+	conn, err := d.Dial(network, proxyAddr(u))
+	if err != nil {
+		return nil, &ProxyError{u.Host, err}
+	}
+
+	conn.SetDeadline(time.Now().Add(d.Timeout))
+
+	tunnel := conn
+	switch u.Scheme {
+	case "http":
+		tunnel, err = connectHTTP(conn, u, addr)
+	case "socks5":
+		err = connectSOCKS5(conn, u, addr)
+	default:
+		err = fmt.Errorf("unsupported scheme: %s", u.Scheme)
+	}
+
+	if err != nil {
+		conn.Close()
+		return nil, &ProxyError{u.Host, err}
+	}
+
+	conn.SetDeadline(time.Time{})
+
+	return tunnel, nil
+}
+
+// proxyAddr returns the address of the proxy with the default port
+// of the scheme if the port is omitted.
+func proxyAddr(u *url.URL) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+
+	port := "1080"
+	if u.Scheme == "http" {
+		port = "8080"
+	}
+
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// connectHTTP establishes a tunnel with the CONNECT method of
+// HTTP/1.1.
+func connectHTTP(conn net.Conn, u *url.URL, addr string) (net.Conn, error) {
+	req := fmt.Sprintf("CONNECT %s HTTP/1.1\r\nHost: %s\r\n", addr, addr)
+	if u.User != nil {
+		password, _ := u.User.Password()
+		auth := base64.StdEncoding.EncodeToString([]byte(u.User.Username() + ":" + password))
+		req += fmt.Sprintf("Proxy-Authorization: Basic %s\r\n", auth)
+	}
+	req += "\r\n"
+
+	_, err := io.WriteString(conn, req)
+	if err != nil {
+		return nil, err
+	}
+
+	br := bufio.NewReader(conn)
+	res, err := http.ReadResponse(br, nil)
+	if err != nil {
+		return nil, err
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("CONNECT failed with %s", res.Status)
+	}
+
+	// The server may have sent data following the response.
+	if br.Buffered() > 0 {
+		return &bufferedConn{conn, br}, nil
+	}
+
+	return conn, nil
+}
+
+// bufferedConn is a net.Conn that reads the buffered data first.
+type bufferedConn struct {
+	net.Conn
+	br *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.br.Read(b)
+}
+
+// SOCKS5 protocol constants defined in RFC 1928 and RFC 1929.
+const (
+	socks5Version      = 0x05
+	socks5AuthNone     = 0x00
+	socks5AuthPassword = 0x02
+	socks5CmdConnect   = 0x01
+	socks5AtypIPv4     = 0x01
+	socks5AtypDomain   = 0x03
+	socks5AtypIPv6     = 0x04
+)
+
+// connectSOCKS5 establishes a tunnel with the CONNECT command of
+// SOCKS5.
+func connectSOCKS5(conn net.Conn, u *url.URL, addr string) error {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return err
+	}
+
+	method := byte(socks5AuthNone)
+	if u.User != nil {
+		method = socks5AuthPassword
+	}
+
+	_, err = conn.Write([]byte{socks5Version, 1, method})
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, 2)
+	_, err = io.ReadFull(conn, buf)
+	if err != nil {
+		return err
+	}
+
+	if buf[0] != socks5Version {
+		return fmt.Errorf("unexpected SOCKS version: %d", buf[0])
+	}
+	if buf[1] != method {
+		return errors.New("no acceptable authentication method")
+	}
+
+	if method == socks5AuthPassword {
+		username := u.User.Username()
+		password, _ := u.User.Password()
+
+		req := []byte{0x01, byte(len(username))}
+		req = append(req, username...)
+		req = append(req, byte(len(password)))
+		req = append(req, password...)
+
+		_, err = conn.Write(req)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.ReadFull(conn, buf)
+		if err != nil {
+			return err
+		}
+
+		if buf[1] != 0x00 {
+			return errors.New("authentication failed")
+		}
+	}
+
+	req := []byte{socks5Version, socks5CmdConnect, 0x00}
+
+	ip := net.ParseIP(host)
+	if ip4 := ip.To4(); ip4 != nil {
+		req = append(req, socks5AtypIPv4)
+		req = append(req, ip4...)
+	} else if ip != nil {
+		req = append(req, socks5AtypIPv6)
+		req = append(req, ip...)
+	} else {
+		req = append(req, socks5AtypDomain, byte(len(host)))
+		req = append(req, host...)
+	}
+
+	req = append(req, 0, 0)
+	binary.BigEndian.PutUint16(req[len(req)-2:], uint16(port))
+
+	_, err = conn.Write(req)
+	if err != nil {
+		return err
+	}
+
+	res := make([]byte, 4)
+	_, err = io.ReadFull(conn, res)
+	if err != nil {
+		return err
+	}
+
+	if res[1] != 0x00 {
+		return fmt.Errorf("CONNECT failed with reply code %d", res[1])
+	}
+
+	// Discard the bound address and port.
+	var addrLen int
+	switch res[3] {
+	case socks5AtypIPv4:
+		addrLen = net.IPv4len
+	case socks5AtypIPv6:
+		addrLen = net.IPv6len
+	case socks5AtypDomain:
+		_, err = io.ReadFull(conn, buf[:1])
+		if err != nil {
+			return err
+		}
+		addrLen = int(buf[0])
+	default:
+		return fmt.Errorf("unexpected address type: %d", res[3])
+	}
+
+	_, err = io.ReadFull(conn, make([]byte, addrLen+2))
+	return err
+}
@@ -0,0 +1,223 @@
+package spec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/summerwind/h2spec/config"
+)
+
+// proxyProtocolSignature is the signature of the PROXY protocol
+// version 2 header.
+var proxyProtocolSignature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// maxProxyProtocolV1Len is the maximum length of the PROXY protocol
+// version 1 header including CRLF.
+const maxProxyProtocolV1Len = 107
+
+// writeProxyHeader writes the PROXY protocol header of the specified
+// version ("v1" or "v2") with the source and destination addresses.
+func writeProxyHeader(w io.Writer, version string, src, dst *net.TCPAddr) error {
+	src4, dst4 := src.IP.To4(), dst.IP.To4()
+	if (src4 == nil) != (dst4 == nil) {
+		return errors.New("PROXY protocol addresses must be of the same family")
+	}
+
+	var header []byte
+
+	switch version {
+	case "v1":
+		proto := "TCP6"
+		if src4 != nil {
+			proto = "TCP4"
+		}
+		header = []byte(fmt.Sprintf("PROXY %s %s %s %d %d\r\n", proto, src.IP, dst.IP, src.Port, dst.Port))
+
+	case "v2":
+		var addrs []byte
+		family := byte(0x21) // TCP over IPv6
+		if src4 != nil {
+			family = 0x11 // TCP over IPv4
+			addrs = append(addrs, src4...)
+			addrs = append(addrs, dst4...)
+		} else {
+			addrs = append(addrs, src.IP.To16()...)
+			addrs = append(addrs, dst.IP.To16()...)
+		}
+		addrs = append(addrs, 0, 0, 0, 0)
+		binary.BigEndian.PutUint16(addrs[len(addrs)-4:], uint16(src.Port))
+		binary.BigEndian.PutUint16(addrs[len(addrs)-2:], uint16(dst.Port))
+
+		header = append(header, proxyProtocolSignature...)
+		header = append(header, 0x21, family, 0, 0) // version 2, PROXY command
+		binary.BigEndian.PutUint16(header[len(header)-2:], uint16(len(addrs)))
+		header = append(header, addrs...)
+
+	default:
+		return fmt.Errorf("Unknown PROXY protocol version: %s", version)
+	}
+
+	_, err := w.Write(header)
+	return err
+}
+
+// sendProxyHeader writes the PROXY protocol header to the connection
+// based on configuration. The addresses of the connection are used
+// unless the source or destination address is configured.
+func sendProxyHeader(c *config.Config, conn net.Conn) error {
+	src, _ := conn.LocalAddr().(*net.TCPAddr)
+	dst, _ := conn.RemoteAddr().(*net.TCPAddr)
+
+	var err error
+	if c.ProxyProtocolSrc != "" {
+		src, err = net.ResolveTCPAddr("tcp", c.ProxyProtocolSrc)
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.ProxyProtocolDst != "" {
+		dst, err = net.ResolveTCPAddr("tcp", c.ProxyProtocolDst)
+		if err != nil {
+			return err
+		}
+	}
+
+	if src == nil || dst == nil {
+		return errors.New("Unable to determine the addresses for PROXY protocol")
+	}
+
+	return writeProxyHeader(conn, c.ProxyProtocol, src, dst)
+}
+
+// readProxyHeader reads the PROXY protocol header of version 1 or 2
+// from the connection and returns the source address. It returns nil
+// if the header does not carry an address, such as the LOCAL command.
+// The connection is read byte by byte for version 1 so that no data
+// following the header is consumed.
+func readProxyHeader(r io.Reader) (net.Addr, error) {
+	// Both versions of the header are at least 12 bytes long.
+	buf := make([]byte, len(proxyProtocolSignature))
+	_, err := io.ReadFull(r, buf)
+	if err != nil {
+		return nil, err
+	}
+
+	if bytes.Equal(buf, proxyProtocolSignature) {
+		return readProxyHeaderV2(r)
+	}
+
+	if !bytes.HasPrefix(buf, []byte("PROXY ")) {
+		return nil, errors.New("Invalid PROXY protocol header")
+	}
+
+	b := make([]byte, 1)
+	for !bytes.HasSuffix(buf, []byte("\r\n")) {
+		if len(buf) >= maxProxyProtocolV1Len {
+			return nil, errors.New("PROXY protocol header is too long")
+		}
+
+		_, err := io.ReadFull(r, b)
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, b[0])
+	}
+
+	fields := strings.Fields(string(buf))
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, fmt.Errorf("Invalid PROXY protocol header: %q", buf)
+	}
+
+	ip := net.ParseIP(fields[2])
+	port, err := strconv.Atoi(fields[4])
+	if ip == nil || err != nil {
+		return nil, fmt.Errorf("Invalid PROXY protocol header: %q", buf)
+	}
+
+	return &net.TCPAddr{IP: ip, Port: port}, nil
+}
+
+func readProxyHeaderV2(r io.Reader) (net.Addr, error) {
+	buf := make([]byte, 4)
+	_, err := io.ReadFull(r, buf)
+	if err != nil {
+		return nil, err
+	}
+
+	if buf[0]>>4 != 2 {
+		return nil, fmt.Errorf("Invalid PROXY protocol version: %d", buf[0]>>4)
+	}
+
+	addrs := make([]byte, binary.BigEndian.Uint16(buf[2:]))
+	_, err = io.ReadFull(r, addrs)
+	if err != nil {
+		return nil, err
+	}
+
+	// The LOCAL command and unsupported address families do not
+	// carry the source address of the client.
+	if buf[0]&0x0f != 0x01 {
+		return nil, nil
+	}
+
+	var ipLen int
+	switch buf[1] {
+	case 0x11:
+		ipLen = net.IPv4len
+	case 0x21:
+		ipLen = net.IPv6len
+	default:
+		return nil, nil
+	}
+
+	if len(addrs) < ipLen*2+4 {
+		return nil, errors.New("PROXY protocol header is too short")
+	}
+
+	ip := net.IP(addrs[:ipLen])
+	port := binary.BigEndian.Uint16(addrs[ipLen*2:])
+
+	return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+}
+
+// acceptProxyHeader reads the PROXY protocol header from the accepted
+// connection and returns a connection whose remote address is the
+// source address in the header.
+func acceptProxyHeader(conn net.Conn, timeout time.Duration) (net.Conn, error) {
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	addr, err := readProxyHeader(conn)
+	conn.SetReadDeadline(time.Time{})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if addr == nil {
+		return conn, nil
+	}
+
+	return &proxiedConn{conn, addr}, nil
+}
+
+// proxiedConn is a net.Conn whose remote address is the address
+// received in the PROXY protocol header.
+type proxiedConn struct {
+	net.Conn
+	remoteAddr net.Addr
+}
+
+func (c *proxiedConn) RemoteAddr() net.Addr {
+	return c.remoteAddr
+}
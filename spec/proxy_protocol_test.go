@@ -0,0 +1,62 @@
+package spec
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"testing"
+)
+
+func TestProxyHeaderRoundTrip(t *testing.T) {
+	tests := []struct {
+		version string
+		src     string
+		dst     string
+	}{
+		{version: "v1", src: "192.0.2.1:56324", dst: "198.51.100.1:443"},
+		{version: "v1", src: "[2001:db8::1]:56324", dst: "[2001:db8::2]:443"},
+		{version: "v2", src: "192.0.2.1:56324", dst: "198.51.100.1:443"},
+		{version: "v2", src: "[2001:db8::1]:56324", dst: "[2001:db8::2]:443"},
+	}
+
+	for i, tt := range tests {
+		src, _ := net.ResolveTCPAddr("tcp", tt.src)
+		dst, _ := net.ResolveTCPAddr("tcp", tt.dst)
+
+		var buf bytes.Buffer
+		err := writeProxyHeader(&buf, tt.version, src, dst)
+		if err != nil {
+			t.Fatalf("#%d unexpected error: %v", i, err)
+		}
+		buf.WriteString("PRI * HTTP/2.0")
+
+		addr, err := readProxyHeader(&buf)
+		if err != nil {
+			t.Fatalf("#%d unexpected error: %v", i, err)
+		}
+
+		if addr.String() != src.String() {
+			t.Errorf("#%d source - expect: %s, got: %s", i, src, addr)
+		}
+
+		rest, _ := ioutil.ReadAll(&buf)
+		if string(rest) != "PRI * HTTP/2.0" {
+			t.Errorf("#%d data following the header was consumed: %q", i, rest)
+		}
+	}
+}
+
+func TestReadProxyHeaderInvalid(t *testing.T) {
+	tests := []string{
+		"PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n",
+		"PROXY TCP4 192.0.2.1\r\n",
+		"PROXY TCP4 192.0.2.1 198.51.100.1 56324 443 " + string(make([]byte, 100)),
+	}
+
+	for i, tt := range tests {
+		_, err := readProxyHeader(bytes.NewBufferString(tt))
+		if err == nil {
+			t.Errorf("#%d expected an error", i)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package spec
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/summerwind/h2spec/config"
+)
+
+// listen starts a local server that handles each connection with
+// the specified function.
+func listen(t *testing.T, handle func(conn net.Conn)) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				handle(conn)
+			}()
+		}
+	}()
+
+	return ln.Addr().String()
+}
+
+// startEchoServer starts the origin server that echoes back the
+// received data.
+func startEchoServer(t *testing.T) string {
+	return listen(t, func(conn net.Conn) {
+		io.Copy(conn, conn)
+	})
+}
+
+// tunnel relays data between the client and the address.
+func tunnel(client net.Conn, addr string) {
+	origin, err := net.Dial("tcp", addr)
+	if err != nil {
+		return
+	}
+	defer origin.Close()
+
+	go io.Copy(origin, client)
+	io.Copy(client, origin)
+}
+
+// startHTTPProxy starts a stand-in of HTTP CONNECT proxy. If auth is
+// not empty, the Proxy-Authorization header must match it.
+func startHTTPProxy(t *testing.T, auth string) string {
+	return listen(t, func(conn net.Conn) {
+		req, err := http.ReadRequest(bufio.NewReader(conn))
+		if err != nil || req.Method != "CONNECT" {
+			fmt.Fprint(conn, "HTTP/1.1 400 Bad Request\r\n\r\n")
+			return
+		}
+
+		if auth != "" && req.Header.Get("Proxy-Authorization") != auth {
+			fmt.Fprint(conn, "HTTP/1.1 407 Proxy Authentication Required\r\n\r\n")
+			return
+		}
+
+		fmt.Fprint(conn, "HTTP/1.1 200 Connection Established\r\n\r\n")
+		tunnel(conn, req.Host)
+	})
+}
+
+// startSOCKS5Proxy starts a stand-in of SOCKS5 proxy. If username is
+// not empty, username and password authentication is required.
+func startSOCKS5Proxy(t *testing.T, username, password string) string {
+	return listen(t, func(conn net.Conn) {
+		buf := make([]byte, 256)
+
+		_, err := io.ReadFull(conn, buf[:2])
+		if err != nil {
+			return
+		}
+		methods := make([]byte, buf[1])
+		_, err = io.ReadFull(conn, methods)
+		if err != nil {
+			return
+		}
+
+		method := byte(socks5AuthNone)
+		if username != "" {
+			method = socks5AuthPassword
+		}
+
+		offered := false
+		for _, m := range methods {
+			offered = offered || m == method
+		}
+		if !offered {
+			conn.Write([]byte{socks5Version, 0xff})
+			return
+		}
+		conn.Write([]byte{socks5Version, method})
+
+		if method == socks5AuthPassword {
+			io.ReadFull(conn, buf[:2])
+			user := make([]byte, buf[1])
+			io.ReadFull(conn, user)
+			io.ReadFull(conn, buf[:1])
+			pass := make([]byte, buf[0])
+			io.ReadFull(conn, pass)
+
+			if string(user) != username || string(pass) != password {
+				conn.Write([]byte{0x01, 0x01})
+				return
+			}
+			conn.Write([]byte{0x01, 0x00})
+		}
+
+		_, err = io.ReadFull(conn, buf[:4])
+		if err != nil || buf[1] != socks5CmdConnect {
+			return
+		}
+
+		var host string
+		switch buf[3] {
+		case socks5AtypIPv4:
+			io.ReadFull(conn, buf[:net.IPv4len])
+			host = net.IP(buf[:net.IPv4len]).String()
+		case socks5AtypDomain:
+			io.ReadFull(conn, buf[:1])
+			name := make([]byte, buf[0])
+			io.ReadFull(conn, name)
+			host = string(name)
+		default:
+			return
+		}
+
+		io.ReadFull(conn, buf[:2])
+		port := binary.BigEndian.Uint16(buf[:2])
+
+		conn.Write([]byte{socks5Version, 0x00, 0x00, socks5AtypIPv4, 0, 0, 0, 0, 0, 0})
+		tunnel(conn, net.JoinHostPort(host, strconv.Itoa(int(port))))
+	})
+}
+
+func TestDialProxy(t *testing.T) {
+	origin := startEchoServer(t)
+
+	httpProxy := startHTTPProxy(t, "")
+	httpAuthProxy := startHTTPProxy(t, "Basic dXNlcjpwYXNz")
+	socksProxy := startSOCKS5Proxy(t, "", "")
+	socksAuthProxy := startSOCKS5Proxy(t, "user", "pass")
+
+	tests := []struct {
+		proxy string
+		ok    bool
+	}{
+		{proxy: "http://" + httpProxy, ok: true},
+		{proxy: "http://user:pass@" + httpAuthProxy, ok: true},
+		{proxy: "http://" + httpAuthProxy, ok: false},
+		{proxy: "http://user:wrong@" + httpAuthProxy, ok: false},
+		{proxy: "socks5://" + socksProxy, ok: true},
+		{proxy: "socks5://user:pass@" + socksAuthProxy, ok: true},
+		{proxy: "socks5://" + socksAuthProxy, ok: false},
+		{proxy: "socks5://user:wrong@" + socksAuthProxy, ok: false},
+	}
+
+	for i, tt := range tests {
+		conn, err := dialProxy(&net.Dialer{Timeout: time.Second}, "tcp", tt.proxy, origin)
+		if !tt.ok {
+			if _, isProxyErr := err.(*ProxyError); !isProxyErr {
+				t.Errorf("#%d %s - expect: ProxyError, got: %v", i, tt.proxy, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("#%d %s - unexpected error: %v", i, tt.proxy, err)
+			continue
+		}
+
+		msg := []byte("hello")
+		conn.Write(msg)
+		buf := make([]byte, len(msg))
+		_, err = io.ReadFull(conn, buf)
+		if err != nil || string(buf) != string(msg) {
+			t.Errorf("#%d %s - expect: %s, got: %s (%v)", i, tt.proxy, msg, buf, err)
+		}
+		conn.Close()
+	}
+}
+
+func TestDialWithProxy(t *testing.T) {
+	origin := startEchoServer(t)
+	host, port, _ := net.SplitHostPort(origin)
+	portNum, _ := strconv.Atoi(port)
+
+	c := &config.Config{
+		Host:    host,
+		Port:    portNum,
+		Timeout: time.Second,
+		Proxy:   "http://user:wrong@" + startHTTPProxy(t, "Basic dXNlcjpwYXNz"),
+	}
+
+	_, err := Dial(c)
+	if _, ok := err.(*ProxyError); !ok {
+		t.Fatalf("expect: ProxyError, got: %v", err)
+	}
+
+	c.Proxy = "socks5://" + startSOCKS5Proxy(t, "", "")
+
+	conn, err := Dial(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+}
+
+func TestProxySetupError(t *testing.T) {
+	origin := startEchoServer(t)
+	host, port, _ := net.SplitHostPort(origin)
+	portNum, _ := strconv.Atoi(port)
+
+	c := &config.Config{
+		Host:    host,
+		Port:    portNum,
+		Timeout: time.Second,
+		Proxy:   "http://user:wrong@" + startHTTPProxy(t, "Basic dXNlcjpwYXNz"),
+	}
+
+	_, check := preflightConnect(c)
+	if !check.Failed || !strings.HasPrefix(check.Message, "Proxy setup error") {
+		t.Errorf("preflight - expect: proxy setup error, got: %s", check.Message)
+	}
+	if !strings.Contains(check.Hint, "--proxy") {
+		t.Errorf("preflight - expect: hint for --proxy, got: %s", check.Hint)
+	}
+
+	tg := &TestGroup{Key: "http2", Section: "1"}
+	tg.AddTestCase(&TestCase{
+		Desc: "test",
+		Run: func(c *config.Config, conn *Conn) error {
+			return nil
+		},
+	})
+
+	state := &RunState{}
+	tg.Test(c, state)
+	if state.Err == nil || !strings.HasPrefix(state.Err.Error(), "Proxy setup error") {
+		t.Errorf("run - expect: proxy setup error, got: %v", state.Err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"crypto/tls"
 	"fmt"
 	"net"
+	"strconv"
 	"time"
 
 	"github.com/summerwind/h2spec/config"
@@ -14,6 +15,7 @@ import (
 type Server struct {
 	listeners []net.Listener
 	config    *config.Config
+	tlsConfig *tls.Config
 	spec      *ClientTestGroup
 }
 
@@ -21,32 +23,25 @@ func Listen(c *config.Config, tg *ClientTestGroup) (*Server, error) {
 	testCases := make(map[int]*ClientTestCase)
 	tg.ClientTestCases(testCases, c, c.FromPort)
 
+	tlsConfig, err := c.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
 	server := &Server{
 		listeners: make([]net.Listener, 0),
 		config:    c,
+		tlsConfig: tlsConfig,
 	}
 
 	for port, tc := range testCases {
-		var err error
-		var listener net.Listener
-
-		addr := fmt.Sprintf("%s:%d", c.Host, port)
-
-		if c.TLS {
-			tlsConfig, err := c.TLSConfig()
-			if err != nil {
-				return nil, err
-			}
-
-			listener, err = tls.Listen("tcp", addr, tlsConfig)
-			if err != nil {
-				return nil, err
-			}
-		} else {
-			listener, err = net.Listen("tcp", addr)
-			if err != nil {
-				return nil, err
-			}
+		addr := net.JoinHostPort(c.Host, strconv.Itoa(port))
+
+		// TLS is applied to each connection since the PROXY protocol
+		// header precedes the TLS handshake.
+		listener, err := net.Listen(c.Network(), addr)
+		if err != nil {
+			return nil, err
 		}
 
 		server.listeners = append(server.listeners, listener)
@@ -64,14 +59,32 @@ func (server *Server) RunListener(listener net.Listener, tc *ClientTestCase) {
 			continue
 		}
 
-		conn, err := Accept(server.config, baseConn)
+		go server.acceptConn(baseConn, tc)
+	}
+}
+
+func (server *Server) acceptConn(baseConn net.Conn, tc *ClientTestCase) {
+	if server.config.AcceptProxyProtocol {
+		proxiedConn, err := acceptProxyHeader(baseConn, server.config.Timeout)
 		if err != nil {
 			log.Println(err)
-			continue
+			baseConn.Close()
+			return
 		}
+		baseConn = proxiedConn
+	}
+
+	if server.tlsConfig != nil {
+		baseConn = tls.Server(baseConn, server.tlsConfig)
+	}
 
-		go server.handleConn(conn, tc)
+	conn, err := Accept(server.config, baseConn)
+	if err != nil {
+		log.Println(err)
+		return
 	}
+
+	server.handleConn(conn, tc)
 }
 
 func (server *Server) Close() {
@@ -86,14 +99,18 @@ func (server *Server) handleConn(conn *Conn, tc *ClientTestCase) {
 		log.Println(groupNames(tc.Parent))
 	}
 
+	c := server.config.WithTimeout(tc.Timeout)
+	conn.Timeout = c.Timeout
+
 	start := time.Now()
-	err := tc.Run(server.config, conn)
+	err := tc.Run(c, conn)
 	end := time.Now()
 
 	// Ensure that connection had been closed
 	go closeConn(conn)
 
 	tr := NewClientTestResult(tc, err, end.Sub(start))
+	tr.Events = conn.events
 
 	if server.config.IsBrowserMode() {
 		// Only log here when browser mode
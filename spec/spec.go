@@ -3,8 +3,8 @@ package spec
 import (
 	"errors"
 	"fmt"
+	"math/rand"
 	"net"
-	"os"
 	"strings"
 	"time"
 
@@ -17,8 +17,30 @@ var (
 	ErrTimeout = errors.New("Timeout")
 	// ErrSkipped is used when the test skipped.
 	ErrSkipped = errors.New("Skipped")
+	// ErrProtocolNegotiation is used when the server did not select
+	// h2 with ALPN.
+	ErrProtocolNegotiation = errors.New("Protocol negotiation failed")
+	// ErrDeadlineExceeded is used when the test was not run because
+	// the maximum duration of the run was exceeded.
+	ErrDeadlineExceeded = errors.New("Maximum duration exceeded")
+	// ErrRunStopped is used when the test was not run because an
+	// error stopped the run.
+	ErrRunStopped = errors.New("Run stopped by an error")
 )
 
+// RunState represents the state of a run of test groups that is
+// shared by their test cases.
+type RunState struct {
+	// Err is the error that stopped the run, such as a server that
+	// did not recover. The test cases after it are not run.
+	Err error
+
+	// last is the test case that was run last. It is marked as the
+	// one that crashed the server if the server stopped responding
+	// before the next test case connected.
+	last *TestCase
+}
+
 // TestGroup represents a group of test case.
 type TestGroup struct {
 	Key         string
@@ -33,6 +55,15 @@ type TestGroup struct {
 	PassedCount  int
 	FailedCount  int
 	SkippedCount int
+	FlakyCount   int
+	NotRunCount  int
+	Duration     time.Duration
+
+	// testOrder and groupOrder are the indexes of the test cases and
+	// the sub groups in the order they are run. They are nil unless
+	// the group is shuffled.
+	testOrder  []int
+	groupOrder []int
 }
 
 // IsRoot returns bool as to whether it is the parent of all groups.
@@ -68,19 +99,25 @@ func (tg *TestGroup) Level() int {
 	return strings.Count(tg.Section, ".") + 1
 }
 
-// Test runs all the tests included in this group.
-func (tg *TestGroup) Test(c *config.Config) {
+// Test runs all the tests included in this group. An error that stops
+// the run is set to the state, and the remaining test cases are
+// reported as not run.
+func (tg *TestGroup) Test(c *config.Config, state *RunState) {
 	level := tg.Level()
 
 	if tg.Strict && !c.Strict {
 		return
 	}
 
-	mode := c.RunMode(tg.ID())
-	if mode == config.RunModeNone {
+	if !tg.selected(c) {
 		return
 	}
 
+	start := time.Now()
+	defer func() {
+		tg.Duration = time.Since(start)
+	}()
+
 	log.SetIndentLevel(level)
 	log.Println(tg.Title())
 	log.SetIndentLevel(level + 1)
@@ -88,24 +125,19 @@ func (tg *TestGroup) Test(c *config.Config) {
 	tests := append(tg.Tests, tg.StrictTests...)
 	tested := false
 
-	for i, tc := range tests {
+	for i := range tests {
+		// The sequence number is based on the registration order so
+		// that the ID of the test case does not depend on the order.
+		if tg.testOrder != nil {
+			i = tg.testOrder[i]
+		}
+		tc := tests[i]
 		seq := i + 1
 
-		err := tc.Test(c, seq)
-		if err != nil {
-			fmt.Printf("\nError: %v\n", err)
-			os.Exit(1)
-		}
+		tc.Test(c, state, seq)
 
 		if tc.Result != nil {
-			if tc.Result.Failed {
-				tg.FailedCount += 1
-			} else if tc.Result.Skipped {
-				tg.SkippedCount += 1
-			} else {
-				tg.PassedCount += 1
-			}
-
+			tg.count(tc.Result, 1)
 			tested = true
 		}
 	}
@@ -114,14 +146,135 @@ func (tg *TestGroup) Test(c *config.Config) {
 		log.PrintBlankLine()
 	}
 
-	for _, g := range tg.Groups {
-		g.Test(c)
+	for i := range tg.Groups {
+		if tg.groupOrder != nil {
+			i = tg.groupOrder[i]
+		}
+		g := tg.Groups[i]
+
+		g.Test(c, state)
 		tg.FailedCount += g.FailedCount
 		tg.SkippedCount += g.SkippedCount
 		tg.PassedCount += g.PassedCount
+		tg.FlakyCount += g.FlakyCount
+		tg.NotRunCount += g.NotRunCount
+	}
+}
+
+// count adds n to the counter of the status of the result.
+func (tg *TestGroup) count(tr *TestResult, n int) {
+	if tr.NotRun {
+		tg.NotRunCount += n
+	} else if tr.Failed {
+		tg.FailedCount += n
+	} else if tr.Skipped {
+		tg.SkippedCount += n
+	} else {
+		tg.PassedCount += n
+	}
+
+	if tr.Flaky {
+		tg.FlakyCount += n
 	}
 }
 
+// contains returns true if stg is this group or one of its sub groups.
+func (tg *TestGroup) contains(stg *TestGroup) bool {
+	for g := stg; g != nil; g = g.Parent {
+		if g == tg {
+			return true
+		}
+	}
+	return false
+}
+
+// selected returns true if any test case of this group or its sub
+// groups is selected to run, so that groups whose test cases are all
+// excluded are not shown.
+func (tg *TestGroup) selected(c *config.Config) bool {
+	if tg.Strict && !c.Strict {
+		return false
+	}
+
+	if c.RunMode(tg.ID()) == config.RunModeNone {
+		return false
+	}
+
+	tests := append(tg.Tests, tg.StrictTests...)
+	for i, tc := range tests {
+		if tc.Strict && !c.Strict {
+			continue
+		}
+
+		id := fmt.Sprintf("%s/%d", tg.ID(), i+1)
+		if c.RunMode(id) != config.RunModeNone {
+			return true
+		}
+	}
+
+	for _, g := range tg.Groups {
+		if g.selected(c) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Shuffle randomizes the order in which the test cases and the sub
+// groups of this group and its sub groups are run.
+func (tg *TestGroup) Shuffle(r *rand.Rand) {
+	tg.testOrder = r.Perm(len(tg.Tests) + len(tg.StrictTests))
+	tg.groupOrder = r.Perm(len(tg.Groups))
+
+	for _, g := range tg.Groups {
+		g.Shuffle(r)
+	}
+}
+
+// TestResults returns the results of all test cases that were run in
+// this group and its sub groups.
+func (tg *TestGroup) TestResults() []*TestResult {
+	var results []*TestResult
+
+	tests := append(tg.Tests, tg.StrictTests...)
+	for _, tc := range tests {
+		if tc.Result != nil && !tc.Result.NotRun {
+			results = append(results, tc.Result)
+		}
+	}
+
+	for _, g := range tg.Groups {
+		results = append(results, g.TestResults()...)
+	}
+
+	return results
+}
+
+// FindTestCase returns the ID of the test case that has the specified
+// description in the group of the specified ID. This group and its sub
+// groups are searched.
+func (tg *TestGroup) FindTestCase(groupID, desc string) (string, bool) {
+	if tg.ID() == groupID {
+		tests := append(tg.Tests, tg.StrictTests...)
+		for i, tc := range tests {
+			if tc.Desc == desc {
+				return fmt.Sprintf("%s/%d", groupID, i+1), true
+			}
+		}
+		return "", false
+	}
+
+	for _, g := range tg.Groups {
+		id, ok := g.FindTestCase(groupID, desc)
+		if ok {
+			return id, true
+		}
+	}
+
+	return "", false
+}
+
 // AddTestGroup registers a group to this group.
 func (tg *TestGroup) AddTestGroup(stg *TestGroup) {
 	stg.Parent = tg
@@ -150,36 +303,152 @@ type TestCase struct {
 	Parent      *TestGroup
 	Result      *TestResult
 	Run         func(c *config.Config, conn *Conn) error
+
+	// Timeout is the timeout of this test case, e.g. a shorter one
+	// for a test case that waits for the absence of a response. It is
+	// relative to config.DefaultTimeout and scales with the configured
+	// timeout. Zero means the configured timeout.
+	Timeout time.Duration
 }
 
-// Test runs itself as a test case.
-func (tc *TestCase) Test(c *config.Config, seq int) error {
+// Test runs itself as a test case. An error that stops the run is set
+// to the state.
+func (tc *TestCase) Test(c *config.Config, state *RunState, seq int) {
 	if tc.Strict && !c.Strict {
-		return nil
+		return
 	}
 
-	mode := c.RunMode(fmt.Sprintf("%s/%d", tc.Parent.ID(), seq))
+	id := fmt.Sprintf("%s/%d", tc.Parent.ID(), seq)
+
+	mode := c.RunMode(id)
 	if mode == config.RunModeNone {
-		return nil
+		return
 	}
 
+	// Test cases may use a different target than the default one.
+	// The health of the server is checked with the default target.
+	base := c
+	c = c.ForTest(id).WithTimeout(tc.Timeout)
+
 	if c.DryRun {
 		msg := fmt.Sprintf("%s %s", seqStr(seq), tc.Desc)
 		log.Println(msg)
 		tc.Result = NewTestResult(tc, seq, nil, time.Duration(0), nil)
-		return nil
+		return
+	}
+
+	// The remaining test cases are reported as not run once the time
+	// budget of the run is exhausted or an error stopped the run.
+	if state.Err != nil || c.DeadlineExceeded() {
+		reason := ErrDeadlineExceeded
+		if state.Err != nil {
+			reason = ErrRunStopped
+		}
+
+		tc.Result = newNotRunResult(tc, seq, reason)
+		tc.Result.Print()
+		return
 	}
 
 	if !c.Verbose {
 		log.Print(gray(fmt.Sprintf("  %s %s", seqStr(seq), tc.Desc)))
 	}
 
+	var tr *TestResult
+	var errs []error
+
+	var before *HookOutput
+	if c.BeforeEach != "" {
+		before = runHook(HookBeforeEach, c.BeforeEach, id, "", "")
+	}
+
+	// Only failures caused by a timeout are retried. Receiving an
+	// unexpected frame is a protocol violation that does not depend
+	// on the responsiveness of the server.
+	for attempt := 1; ; attempt++ {
+		var err error
+
+		tr, err = tc.run(c, seq)
+		if err != nil && c.HealthCheck {
+			tr, err = tc.runAfterRecovery(base, c, state, seq, err)
+		}
+		if err != nil {
+			msg := red(fmt.Sprintf("%s %s %s", "×", seqStr(seq), tc.Desc))
+			log.ResetLine()
+			log.Println(msg)
+			state.Err = runError(c, err)
+			tc.Result = newNotRunResult(tc, seq, state.Err)
+			return
+		}
+
+		tr.Attempts = attempt
+		tr.RetriedErrors = errs
+
+		if !tr.Failed || !tr.Timeout || attempt > c.Retries || c.DeadlineExceeded() {
+			break
+		}
+
+		errs = append(errs, tr.Error)
+		if c.Verbose {
+			log.Println(yellow(fmt.Sprintf("     retrying after timeout (attempt %d of %d)", attempt+1, c.Retries+1)))
+		}
+	}
+
+	tr.Flaky = !tr.Failed && len(tr.RetriedErrors) > 0
+
+	// The server is checked before the after-each hook so that the
+	// hook receives the result of the crashed test case.
+	if c.HealthCheck {
+		crashed, err := waitForServer(base)
+		if crashed {
+			tr.markCrashed()
+		}
+
+		if err != nil {
+			log.ResetLine()
+			tr.Print()
+			tc.Result = tr
+			state.last = tc
+			state.Err = err
+			return
+		}
+	}
+
+	if before != nil {
+		tr.Hooks = append(tr.Hooks, before)
+	}
+
+	if c.AfterEach != "" {
+		var addr string
+		if tr.SourceAddr != nil {
+			addr = tr.SourceAddr.String()
+		}
+		tr.Hooks = append(tr.Hooks, runHook(HookAfterEach, c.AfterEach, id, addr, hookResult(tr)))
+	}
+
+	log.ResetLine()
+	tr.Print()
+	tc.Result = tr
+	state.last = tc
+}
+
+// runError returns the error that stops the run for the error of
+// running a test case.
+func runError(c *config.Config, err error) error {
+	var proxyErr *ProxyError
+	if errors.As(err, &proxyErr) {
+		return fmt.Errorf("Proxy setup error: unable to connect to %s through %s: %v", c.Addr(), proxyErr.Proxy, proxyErr.Err)
+	}
+
+	return err
+}
+
+// run runs the test case once on a new connection and returns its
+// result.
+func (tc *TestCase) run(c *config.Config, seq int) (*TestResult, error) {
 	conn, err := Dial(c)
 	if err != nil {
-		msg := red(fmt.Sprintf("%s %s %s", "×", seqStr(seq), tc.Desc))
-		log.ResetLine()
-		log.Println(msg)
-		return err
+		return nil, err
 	}
 	defer conn.Close()
 
@@ -191,13 +460,13 @@ func (tc *TestCase) Test(c *config.Config, seq int) error {
 	err = tc.Run(c, conn)
 	end := time.Now()
 
-	log.ResetLine()
-
 	tr := NewTestResult(tc, seq, err, end.Sub(start), conn.LocalAddr())
-	tr.Print()
-	tc.Result = tr
+	tr.Handshake = conn.handshakeDuration
+	tr.FirstFrame = conn.firstFrameDuration
+	tr.MaxWait = conn.maxWaitDuration
+	tr.Events = conn.events
 
-	return nil
+	return tr, nil
 }
 
 // TestError represents a error result of test case and implements
@@ -220,20 +489,56 @@ type TestResult struct {
 	Duration   time.Duration
 	SourceAddr net.Addr
 
+	// Handshake is the time taken by the HTTP/2 handshake.
+	Handshake time.Duration
+	// FirstFrame is the time from the first request to the first
+	// frame of a stream or GOAWAY frame received from the server. It
+	// is zero if no request was sent or no such frame was received.
+	FirstFrame time.Duration
+	// MaxWait is the longest time spent waiting for a single frame.
+	MaxWait time.Duration
+
+	// Events contains the events received during the test.
+	Events []string
+
+	// Attempts is the number of times the test case was run.
+	Attempts int
+	// RetriedErrors contains the errors of the failed attempts that
+	// were retried.
+	RetriedErrors []error
+
+	// Hooks contains the output of the hook commands run before and
+	// after the test case.
+	Hooks []*HookOutput
+
 	Skipped bool
 	Failed  bool
+	// Timeout is true if the test case failed because the server did
+	// not respond in time.
+	Timeout bool
+	// Flaky is true if the test case passed after being retried.
+	Flaky bool
+	// CrashedServer is true if the server stopped responding after
+	// the test case.
+	CrashedServer bool
+	// NotRun is true if the test case was not run because the
+	// maximum duration of the run was exceeded or an error stopped
+	// the run.
+	NotRun bool
 }
 
 // NewTestResult returns a TestResult.
 func NewTestResult(tc *TestCase, seq int, err error, d time.Duration, addr net.Addr) *TestResult {
 	skipped := false
 	failed := false
+	timeout := false
 
 	if err != nil {
 		if err == ErrSkipped {
 			skipped = true
 		} else {
 			failed = true
+			timeout = IsTimeoutError(err)
 		}
 	}
 
@@ -243,13 +548,55 @@ func NewTestResult(tc *TestCase, seq int, err error, d time.Duration, addr net.A
 		Error:      err,
 		Duration:   d,
 		SourceAddr: addr,
+		Attempts:   1,
 		Skipped:    skipped,
 		Failed:     failed,
+		Timeout:    timeout,
 	}
 
 	return &tr
 }
 
+// newNotRunResult returns a TestResult of the test case that was not
+// run for the reason, such as ErrDeadlineExceeded.
+func newNotRunResult(tc *TestCase, seq int, reason error) *TestResult {
+	return &TestResult{
+		TestCase: tc,
+		Sequence: seq,
+		Error:    reason,
+		NotRun:   true,
+	}
+}
+
+// IsTimeoutError returns true if the error of a test case was caused
+// by a timeout rather than by an unexpected response of the server.
+func IsTimeoutError(err error) bool {
+	if err == ErrTimeout {
+		return true
+	}
+
+	testErr, ok := err.(*TestError)
+	return ok && testErr.Actual == EventTimeout.String()
+}
+
+// markCrashed marks the test case as the one that crashed the server.
+// A test case that passed is turned into a failure.
+func (tr *TestResult) markCrashed() {
+	tr.CrashedServer = true
+	tr.Flaky = false
+
+	if !tr.Failed {
+		tr.Failed = true
+		tr.Skipped = false
+		tr.Error = ErrServerCrashed
+	}
+}
+
+// ID returns the unique ID of the test case of this result.
+func (tr *TestResult) ID() string {
+	return fmt.Sprintf("%s/%d", tr.TestCase.Parent.ID(), tr.Sequence)
+}
+
 // Print prints the result of test case.
 func (tr *TestResult) Print() {
 	tc := tr.TestCase
@@ -261,12 +608,35 @@ func (tr *TestResult) Print() {
 		return
 	}
 
+	if tr.NotRun {
+		log.Println(fmt.Sprintf("%s %s %s %s", yellow("-"), gray(seq), gray(desc), yellow("(not run)")))
+		return
+	}
+
+	if tr.Flaky {
+		note := fmt.Sprintf("(flaky, passed on attempt %d)", tr.Attempts)
+		log.Println(fmt.Sprintf("%s %s %s %s", yellow("✔"), gray(seq), gray(desc), yellow(note)))
+		return
+	}
+
 	if !tr.Failed {
 		log.Println(fmt.Sprintf("%s %s %s", green("✔"), gray(seq), gray(desc)))
 		return
 	}
 
-	log.Println(red(fmt.Sprintf("%s %s %s", "×", seq, desc)))
+	var notes []string
+	if tr.Attempts > 1 {
+		notes = append(notes, fmt.Sprintf("(failed %d attempts)", tr.Attempts))
+	}
+	if tr.CrashedServer {
+		notes = append(notes, "(crashed server)")
+	}
+
+	if len(notes) > 0 {
+		log.Println(red(fmt.Sprintf("%s %s %s %s", "×", seq, desc, strings.Join(notes, " "))))
+	} else {
+		log.Println(red(fmt.Sprintf("%s %s %s", "×", seq, desc)))
+	}
 	err, ok := tr.Error.(*TestError)
 	if ok {
 		level := log.IndentLevel
@@ -284,6 +654,7 @@ func (tr *TestResult) Print() {
 			log.Println(yellow(fmt.Sprintf("   %s%s", label, ex)))
 		}
 		log.Println(green(fmt.Sprintf("     Actual: %s", err.Actual)))
+		tr.printHooks()
 
 		return
 	}
@@ -292,6 +663,24 @@ func (tr *TestResult) Print() {
 	} else {
 		log.Println(red(fmt.Sprintf("Error: %v", err)))
 	}
+	tr.printHooks()
+}
+
+// printHooks prints the output of the hook commands.
+func (tr *TestResult) printHooks() {
+	for _, h := range tr.Hooks {
+		label := fmt.Sprintf("   %s:", h.Name)
+		if h.Error != nil {
+			label = fmt.Sprintf("%s (%v)", label, h.Error)
+		}
+		log.Println(gray(label))
+
+		for _, line := range strings.Split(strings.TrimRight(h.Output, "\n"), "\n") {
+			if line != "" {
+				log.Println(gray(fmt.Sprintf("     %s", line)))
+			}
+		}
+	}
 }
 
 func seqStr(seq int) string {
@@ -0,0 +1,88 @@
+package spec
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/summerwind/h2spec/config"
+)
+
+// runShuffled runs a test suite of two groups of four test cases in
+// the order of the seed. It returns the descriptions of the test cases
+// in the order they were run, and the IDs of their results by their
+// descriptions.
+func runShuffled(t *testing.T, c *config.Config, seed int64) ([]string, map[string]string) {
+	var order []string
+
+	root := &TestGroup{Key: "http2"}
+	for _, section := range []string{"1", "2"} {
+		tg := &TestGroup{Key: "http2", Section: section}
+		root.AddTestGroup(tg)
+
+		for i := 1; i <= 4; i++ {
+			desc := fmt.Sprintf("%s-%d", section, i)
+			tg.AddTestCase(&TestCase{
+				Desc: desc,
+				Run: func(c *config.Config, conn *Conn) error {
+					order = append(order, desc)
+					return nil
+				},
+			})
+		}
+	}
+
+	root.Shuffle(rand.New(rand.NewSource(seed)))
+
+	state := &RunState{}
+	root.Test(c, state)
+	if state.Err != nil {
+		t.Fatal(state.Err)
+	}
+
+	ids := map[string]string{}
+	for _, tr := range root.TestResults() {
+		ids[tr.TestCase.Desc] = tr.ID()
+	}
+
+	return order, ids
+}
+
+func TestShuffle(t *testing.T) {
+	host, port, _ := net.SplitHostPort(startEchoServer(t))
+	portNum, _ := strconv.Atoi(port)
+	c := &config.Config{Host: host, Port: portNum, Timeout: time.Second}
+
+	order1, ids1 := runShuffled(t, c, 1)
+	order2, ids2 := runShuffled(t, c, 1)
+	order3, _ := runShuffled(t, c, 2)
+
+	if len(order1) != 8 {
+		t.Fatalf("expect: 8 test cases, got: %v", order1)
+	}
+
+	// The same seed runs the test cases in the same order.
+	if s1, s2 := strings.Join(order1, " "), strings.Join(order2, " "); s1 != s2 {
+		t.Errorf("same seed - expect: %s, got: %s", s1, s2)
+	}
+
+	if s1, s3 := strings.Join(order1, " "), strings.Join(order3, " "); s1 == s3 {
+		t.Errorf("different seed - expect an order other than: %s", s1)
+	}
+
+	// The IDs are based on the registration order, not on the order
+	// in which the test cases were run.
+	for desc, id := range ids1 {
+		expected := "http2/" + strings.Replace(desc, "-", "/", 1)
+		if id != expected {
+			t.Errorf("%s - expect: %s, got: %s", desc, expected, id)
+		}
+		if ids2[desc] != id {
+			t.Errorf("%s - expect: %s, got: %s", desc, id, ids2[desc])
+		}
+	}
+}
@@ -2,8 +2,10 @@ package spec
 
 import (
 	"fmt"
+	"net"
 	"os"
 	"os/exec"
+	"strconv"
 	"strings"
 	"time"
 
@@ -11,6 +13,11 @@ import (
 	"github.com/summerwind/h2spec/log"
 )
 
+// execGracePeriod is the time given to the client command to start,
+// connect to the server and exit in addition to the timeout of the
+// test case.
+const execGracePeriod = 1 * time.Second
+
 // ClientTestGroup represents a group of test case.
 type ClientTestGroup struct {
 	Key     string
@@ -148,6 +155,11 @@ type ClientTestCase struct {
 	Result      *ClientTestResult
 	Run         func(c *config.Config, conn *Conn) error
 
+	// Timeout is the timeout of this test case. It is relative to
+	// config.DefaultTimeout and scales with the configured timeout.
+	// Zero means the configured timeout.
+	Timeout time.Duration
+
 	Port int
 	Done chan bool
 }
@@ -180,13 +192,18 @@ func (tc *ClientTestCase) Test(c *config.Config) error {
 			tc.Result.Print()
 		}
 		return nil
-	case <-time.After(time.Duration(3) * time.Second):
+	case <-time.After(c.WithTimeout(tc.Timeout).Timeout + execGracePeriod):
 		return ErrTimeout
 	}
 }
 
+// ID returns the unique ID of this test case.
+func (tc *ClientTestCase) ID() string {
+	return fmt.Sprintf("%s/%d", tc.Parent.ID(), tc.Seq)
+}
+
 func (tc *ClientTestCase) FullPath(c *config.Config) string {
-	return fmt.Sprintf("%s://%s:%d/", c.Scheme(), c.Host, tc.Port)
+	return fmt.Sprintf("%s://%s/", c.Scheme(), net.JoinHostPort(c.Host, strconv.Itoa(tc.Port)))
 }
 
 // ClientTestResult represents a result of test case.
@@ -195,6 +212,9 @@ type ClientTestResult struct {
 	Error          error
 	Duration       time.Duration
 
+	// Events contains the events received during the test.
+	Events []string
+
 	Skipped bool
 	Failed  bool
 }
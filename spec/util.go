@@ -4,6 +4,9 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"net"
+	"strconv"
+	"strings"
 
 	"github.com/fatih/color"
 	"github.com/summerwind/h2spec/config"
@@ -62,27 +65,70 @@ func CommonHeaders(c *config.Config) []hpack.HeaderField {
 		}
 	}
 
-	if defaultPort {
-		authority = c.Host
+	if c.Authority != "" {
+		authority = c.Authority
 	} else {
-		authority = c.Addr()
+		// The zone of a scoped IPv6 address only identifies the
+		// interface on this host and is not part of the authority.
+		host := c.Host
+		if i := strings.LastIndex(host, "%"); i >= 0 && strings.Contains(host, ":") {
+			host = host[:i]
+		}
+
+		if defaultPort {
+			authority = host
+			if strings.Contains(authority, ":") {
+				authority = "[" + authority + "]"
+			}
+		} else {
+			authority = net.JoinHostPort(host, strconv.Itoa(c.Port))
+		}
+	}
+
+	method := "GET"
+	if c.Method != "" {
+		method = c.Method
 	}
 
-	return []hpack.HeaderField{
-		HeaderField(":method", "GET"),
+	// Tests replace the pseudo-header fields by index, so additional
+	// header fields must follow them.
+	headers := []hpack.HeaderField{
+		HeaderField(":method", method),
 		HeaderField(":scheme", scheme),
 		HeaderField(":path", c.Path),
 		HeaderField(":authority", authority),
 	}
+
+	return append(headers, customHeaders(c)...)
 }
 
 // CommonRespHeaders returns a array of header field of HPACK contained
 // common http headers used in various test case.
 func CommonRespHeaders(c *config.Config) []hpack.HeaderField {
-	return []hpack.HeaderField{
-		HeaderField(":status", "200"),
+	status := 200
+	if c.Status != 0 {
+		status = c.Status
+	}
+
+	headers := []hpack.HeaderField{
+		HeaderField(":status", strconv.Itoa(status)),
 		HeaderField("access-control-allow-origin", "*"),
 	}
+
+	return append(headers, customHeaders(c)...)
+}
+
+// customHeaders returns the header fields specified by the user.
+func customHeaders(c *config.Config) []hpack.HeaderField {
+	var headers []hpack.HeaderField
+	for _, header := range c.Headers {
+		name, value, err := config.ParseHeader(header)
+		if err != nil {
+			continue
+		}
+		headers = append(headers, HeaderField(name, value))
+	}
+	return headers
 }
 
 // DummyHeaders returns a array of header field of HPACK contained
@@ -111,38 +157,100 @@ func DummyRespHeaders(c *config.Config, len int) []hpack.HeaderField {
 	return headers
 }
 
+// WriteRequest sends the request of the configuration on the stream:
+// the common header fields and the body, if any. Test cases that
+// depend on the response measured by ServerDataLength send it so that
+// the server responds in the same way.
+func WriteRequest(c *config.Config, conn *Conn, streamID uint32) error {
+	hp := http2.HeadersFrameParam{
+		StreamID:      streamID,
+		EndStream:     c.Body == nil,
+		EndHeaders:    true,
+		BlockFragment: conn.EncodeHeaders(CommonHeaders(c)),
+	}
+
+	err := conn.WriteHeaders(hp)
+	if err != nil || c.Body == nil {
+		return err
+	}
+
+	return conn.WriteBody(streamID, c.Body)
+}
+
 // ServerDataLength returns the total length of the DATA frame of /.
+// The request has the body of the configuration, if any.
 func ServerDataLength(c *config.Config) (int, error) {
-	conn, err := Dial(c)
+	res, err := SendRequest(c, CommonHeaders(c), c.Body)
 	if err != nil {
 		return 0, err
 	}
 
+	return res.DataLength, nil
+}
+
+// Response represents the response of the server to a request sent
+// by SendRequest.
+type Response struct {
+	Status     string
+	DataLength int
+}
+
+// SendRequest sends a request with the header fields on a new
+// connection and returns the response. The body is sent in DATA
+// frames within the flow control windows of the server unless it is
+// nil.
+func SendRequest(c *config.Config, headers []hpack.HeaderField, body []byte) (*Response, error) {
+	conn, err := Dial(c)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
 	err = conn.Handshake()
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
-	headers := CommonHeaders(c)
 	hp := http2.HeadersFrameParam{
 		StreamID:      1,
-		EndStream:     true,
+		EndStream:     body == nil,
 		EndHeaders:    true,
 		BlockFragment: conn.EncodeHeaders(headers),
 	}
 	conn.WriteHeaders(hp)
 
-	len := 0
+	if body != nil {
+		err = conn.WriteBody(1, body)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	res := &Response{}
+	var block []byte
 	done := false
 	for !conn.Closed {
 		ev := conn.WaitEvent()
 
 		switch event := ev.(type) {
 		case DataFrameEvent:
-			len += int(event.Header().Length)
+			res.DataLength += int(event.Header().Length)
 			done = event.StreamEnded()
 		case HeadersFrameEvent:
+			block = append(block, event.HeaderBlockFragment()...)
+			if event.HeadersEnded() {
+				res.decodeStatus(conn, block)
+				block = nil
+			}
 			done = event.StreamEnded()
+		case ContinuationFrameEvent:
+			block = append(block, event.HeaderBlockFragment()...)
+			if event.HeadersEnded() {
+				res.decodeStatus(conn, block)
+				block = nil
+			}
+		case RSTStreamFrameEvent, GoAwayFrameEvent, TimeoutEvent, ErrorEvent:
+			return nil, errors.New(ev.String())
 		}
 
 		if done {
@@ -151,8 +259,105 @@ func ServerDataLength(c *config.Config) (int, error) {
 	}
 
 	if !done {
-		return 0, errors.New("Unable to get server data length")
+		return nil, errors.New("Unable to get server data length")
+	}
+
+	return res, nil
+}
+
+// bodyWriter sends a request body as DATA frames that fit in the flow
+// control windows of the server. The rest of the body is sent when the
+// server opens the windows with WINDOW_UPDATE frames.
+type bodyWriter struct {
+	conn         *Conn
+	streamID     uint32
+	body         []byte
+	connWindow   int
+	streamWindow int
+	done         bool
+}
+
+func newBodyWriter(conn *Conn, streamID uint32, body []byte) *bodyWriter {
+	streamWindow := DefaultWindowSize
+	if val, ok := conn.Settings[http2.SettingInitialWindowSize]; ok {
+		streamWindow = int(val)
+	}
+
+	return &bodyWriter{
+		conn:         conn,
+		streamID:     streamID,
+		body:         body,
+		connWindow:   DefaultWindowSize,
+		streamWindow: streamWindow,
+	}
+}
+
+// write sends as much of the rest of the body as the windows allow.
+// The last DATA frame ends the stream.
+func (w *bodyWriter) write() error {
+	for !w.done {
+		n := len(w.body)
+		if n > DefaultFrameSize {
+			n = DefaultFrameSize
+		}
+		if n > w.connWindow {
+			n = w.connWindow
+		}
+		if n > w.streamWindow {
+			n = w.streamWindow
+		}
+		if n <= 0 && len(w.body) > 0 {
+			return nil
+		}
+
+		end := n == len(w.body)
+		err := w.conn.WriteData(w.streamID, end, w.body[:n])
+		if err != nil {
+			return err
+		}
+
+		w.body = w.body[n:]
+		w.connWindow -= n
+		w.streamWindow -= n
+		w.done = end
+	}
+
+	return nil
+}
+
+// update applies the WINDOW_UPDATE frame to the windows.
+func (w *bodyWriter) update(ev WindowUpdateFrameEvent) {
+	switch ev.Header().StreamID {
+	case 0:
+		w.connWindow += int(ev.Increment)
+	case w.streamID:
+		w.streamWindow += int(ev.Increment)
+	}
+}
+
+// decodeStatus sets the status of the response from the header block.
+// Interim responses are overwritten by the final response.
+func (res *Response) decodeStatus(conn *Conn, block []byte) {
+	status := conn.DecodeStatus(block)
+	if status != "" {
+		res.Status = status
+	}
+}
+
+// DecodeStatus returns the value of the :status pseudo-header field of
+// the header block. It returns an empty string if the header block
+// cannot be decoded or has no :status pseudo-header field.
+func (conn *Conn) DecodeStatus(block []byte) string {
+	fields, err := conn.decoder.DecodeFull(block)
+	if err != nil {
+		return ""
+	}
+
+	for _, f := range fields {
+		if f.Name == ":status" {
+			return f.Value
+		}
 	}
 
-	return len, nil
+	return ""
 }
@@ -0,0 +1,89 @@
+package spec
+
+import (
+	"bytes"
+	"testing"
+
+	"golang.org/x/net/http2"
+
+	"github.com/summerwind/h2spec/config"
+)
+
+func TestCommonHeadersAuthority(t *testing.T) {
+	tests := []struct {
+		host      string
+		port      int
+		tls       bool
+		authority string
+		expected  string
+	}{
+		{host: "127.0.0.1", port: 8080, expected: "127.0.0.1:8080"},
+		{host: "localhost", port: 80, expected: "localhost"},
+		{host: "localhost", port: 443, tls: true, expected: "localhost"},
+		{host: "localhost", port: 80, tls: true, expected: "localhost:80"},
+		{host: "::1", port: 80, expected: "[::1]"},
+		{host: "::1", port: 8080, expected: "[::1]:8080"},
+		{host: "fe80::1%eth0", port: 80, expected: "[fe80::1]"},
+		{host: "fe80::1%eth0", port: 8080, expected: "[fe80::1]:8080"},
+		{host: "fe80::1%eth0", port: 8080, authority: "example.com", expected: "example.com"},
+	}
+
+	for _, tt := range tests {
+		c := &config.Config{Host: tt.host, Port: tt.port, TLS: tt.tls, Authority: tt.authority, Path: "/"}
+		authority := CommonHeaders(c)[3].Value
+		if authority != tt.expected {
+			t.Errorf("%s port %d - expect: %s, got: %s", tt.host, tt.port, tt.expected, authority)
+		}
+	}
+}
+
+func TestBodyWriter(t *testing.T) {
+	rc := &recordConn{}
+	conn := &Conn{
+		Conn:     rc,
+		Settings: map[http2.SettingID]uint32{http2.SettingInitialWindowSize: 20000},
+		framer:   http2.NewFramer(rc, rc),
+	}
+
+	body := bytes.Repeat([]byte("a"), 80000)
+	bw := newBodyWriter(conn, 1, body)
+
+	// The body is limited by the window of the stream.
+	err := bw.write()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent := dataLength(rc.writes); sent != 20000 || bw.done {
+		t.Errorf("expect: 20000 bytes sent, got: %d bytes (done: %v)", sent, bw.done)
+	}
+
+	// The connection window is exhausted before the stream window.
+	bw.update(windowUpdate(1, 80000))
+	bw.write()
+	if sent := dataLength(rc.writes); sent != DefaultWindowSize || bw.done {
+		t.Errorf("expect: %d bytes sent, got: %d bytes (done: %v)", DefaultWindowSize, sent, bw.done)
+	}
+
+	bw.update(windowUpdate(0, 40000))
+	bw.write()
+	if sent := dataLength(rc.writes); sent != len(body) || !bw.done {
+		t.Errorf("expect: %d bytes sent, got: %d bytes (done: %v)", len(body), sent, bw.done)
+	}
+}
+
+// dataLength returns the total length of the payload of the frames
+// written by the framer, one frame per write.
+func dataLength(writes [][]byte) int {
+	n := 0
+	for _, w := range writes {
+		n += len(w) - 9
+	}
+	return n
+}
+
+func windowUpdate(streamID, incr uint32) WindowUpdateFrameEvent {
+	var ev WindowUpdateFrameEvent
+	ev.Increment = incr
+	ev.StreamID = streamID
+	return ev
+}
@@ -3,6 +3,7 @@ package spec
 import (
 	"fmt"
 	"reflect"
+	"time"
 
 	"golang.org/x/net/http2"
 )
@@ -358,3 +359,70 @@ func VerifyErrorCode(codes []http2.ErrCode, code http2.ErrCode) bool {
 	}
 	return false
 }
+
+// VerifyPingFrameOrGoAwayFrame verifies whether a PING frame with
+// ACK flag and the specified data or a GOAWAY frame with one of the
+// specified error codes has received within the timeout. Other
+// frames received in the meantime are ignored.
+func VerifyPingFrameOrGoAwayFrame(conn *Conn, data [8]byte, codes ...http2.ErrCode) error {
+	var actual Event
+
+	passed := false
+	deadline := time.Now().Add(conn.Timeout)
+
+	for !conn.Closed {
+		if time.Now().After(deadline) {
+			actual = TimeoutEvent{}
+			break
+		}
+
+		ev := conn.WaitEvent()
+
+		switch event := ev.(type) {
+		case PingFrameEvent:
+			passed = event.IsAck() && reflect.DeepEqual(event.Data, data)
+		case GoAwayFrameEvent:
+			passed = VerifyErrorCode(codes, event.ErrCode)
+			actual = event
+		case ConnectionClosedEvent:
+			// Keep the GOAWAY frame as the actual event since it
+			// explains why the connection was closed.
+			if actual == nil || actual.Type() != EventGoAwayFrame {
+				actual = event
+			}
+		case TimeoutEvent:
+			if actual == nil {
+				actual = event
+			}
+		}
+
+		if passed {
+			break
+		}
+	}
+
+	if !passed {
+		var actualStr string
+
+		expected := []string{
+			fmt.Sprintf("PING Frame (length:8, flags:0x01, stream_id:0, opaque_data:%s)", data),
+		}
+		for _, code := range codes {
+			expected = append(expected, fmt.Sprintf(ExpectedGoAwayFrame, code))
+		}
+
+		f, ok := actual.(GoAwayFrameEvent)
+		if ok {
+			actualStr = fmt.Sprintf("GOAWAY Frame (Error Code: %s)", f.ErrCode)
+		} else {
+			actualStr = actual.String()
+		}
+
+		return &TestError{
+			Expected: expected,
+			Actual:   actualStr,
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,155 @@
+package spec
+
+import (
+	"bytes"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/summerwind/h2spec/config"
+)
+
+const (
+	// maxCoalesceLen is the length of the buffered data above which
+	// the coalesce mode writes it without waiting for the next read.
+	maxCoalesceLen = 65536
+	// coalesceDelay is the time after which the buffered data is
+	// written even if the connection is not read, so that a test
+	// case that is waiting in another goroutine does not hang.
+	coalesceDelay = 50 * time.Millisecond
+)
+
+// writeModeConn is a net.Conn that changes the way the data written
+// by the test cases is delivered to the server. Each write usually
+// contains a single frame.
+type writeModeConn struct {
+	net.Conn
+
+	mode string
+	rand *rand.Rand
+
+	// buf contains the data of the coalesce mode that has not been
+	// written yet.
+	mu    sync.Mutex
+	buf   bytes.Buffer
+	timer *time.Timer
+}
+
+// wrapWriteMode returns the connection that writes in the write mode
+// of the configuration. The connection itself is returned in the
+// frame mode.
+func wrapWriteMode(c *config.Config, conn net.Conn) net.Conn {
+	mode, seed, err := config.ParseWriteMode(c.WriteMode)
+	if err != nil || mode == config.WriteModeFrame {
+		return conn
+	}
+
+	return &writeModeConn{
+		Conn: conn,
+		mode: mode,
+		rand: rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Write writes the data one byte at a time, in chunks of random
+// length or buffers it until the next read depending on the mode.
+func (conn *writeModeConn) Write(b []byte) (int, error) {
+	switch conn.mode {
+	case config.WriteModeByteByByte:
+		return conn.writeChunks(b, func(remain int) int {
+			return 1
+		})
+
+	case config.WriteModeRandomSplit:
+		return conn.writeChunks(b, func(remain int) int {
+			return conn.rand.Intn(remain) + 1
+		})
+
+	case config.WriteModeCoalesce:
+		conn.mu.Lock()
+		defer conn.mu.Unlock()
+
+		if conn.buf.Len() == 0 {
+			conn.timer = time.AfterFunc(coalesceDelay, func() {
+				conn.mu.Lock()
+				conn.flush()
+				conn.mu.Unlock()
+			})
+		}
+
+		conn.buf.Write(b)
+		if conn.buf.Len() >= maxCoalesceLen {
+			err := conn.flush()
+			if err != nil {
+				return 0, err
+			}
+		}
+		return len(b), nil
+	}
+
+	return conn.Conn.Write(b)
+}
+
+// writeChunks writes the data in chunks whose length is returned by
+// size for the remaining length.
+func (conn *writeModeConn) writeChunks(b []byte, size func(remain int) int) (int, error) {
+	written := 0
+
+	for written < len(b) {
+		end := written + size(len(b)-written)
+
+		n, err := conn.Conn.Write(b[written:end])
+		written += n
+		if err != nil {
+			return written, err
+		}
+	}
+
+	return written, nil
+}
+
+// Read writes the buffered data of the coalesce mode before reading,
+// since the server may not respond until it receives the data.
+func (conn *writeModeConn) Read(b []byte) (int, error) {
+	if conn.mode == config.WriteModeCoalesce {
+		conn.mu.Lock()
+		err := conn.flush()
+		conn.mu.Unlock()
+
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return conn.Conn.Read(b)
+}
+
+// Close writes the buffered data of the coalesce mode and closes the
+// connection.
+func (conn *writeModeConn) Close() error {
+	if conn.mode == config.WriteModeCoalesce {
+		conn.mu.Lock()
+		conn.flush()
+		conn.mu.Unlock()
+	}
+
+	return conn.Conn.Close()
+}
+
+// flush writes the buffered data in a single write. The caller must
+// hold the lock.
+func (conn *writeModeConn) flush() error {
+	if conn.timer != nil {
+		conn.timer.Stop()
+		conn.timer = nil
+	}
+
+	if conn.buf.Len() == 0 {
+		return nil
+	}
+
+	_, err := conn.Conn.Write(conn.buf.Bytes())
+	conn.buf.Reset()
+	return err
+}
@@ -0,0 +1,74 @@
+package spec
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/summerwind/h2spec/config"
+)
+
+// recordConn is a net.Conn that records the data of each write.
+type recordConn struct {
+	net.Conn
+	writes [][]byte
+}
+
+func (conn *recordConn) Write(b []byte) (int, error) {
+	conn.writes = append(conn.writes, append([]byte{}, b...))
+	return len(b), nil
+}
+
+func (conn *recordConn) Read(b []byte) (int, error) {
+	return 0, io.EOF
+}
+
+func TestWriteMode(t *testing.T) {
+	frames := [][]byte{[]byte("first frame"), []byte("second frame")}
+	data := bytes.Join(frames, nil)
+
+	tests := []struct {
+		mode   string
+		writes int
+	}{
+		{mode: config.WriteModeFrame, writes: 2},
+		{mode: config.WriteModeByteByByte, writes: len(data)},
+		{mode: config.WriteModeCoalesce, writes: 1},
+	}
+
+	for _, tt := range tests {
+		rc := &recordConn{}
+		conn := wrapWriteMode(&config.Config{WriteMode: tt.mode}, rc)
+
+		for _, f := range frames {
+			conn.Write(f)
+		}
+		conn.Read(make([]byte, 1))
+
+		if len(rc.writes) != tt.writes {
+			t.Errorf("%s - expect: %d writes, got: %d", tt.mode, tt.writes, len(rc.writes))
+		}
+		if !bytes.Equal(bytes.Join(rc.writes, nil), data) {
+			t.Errorf("%s - expect: %q, got: %q", tt.mode, data, bytes.Join(rc.writes, nil))
+		}
+	}
+
+	// The random split is reproducible with the same seed.
+	var splits [][][]byte
+	for i := 0; i < 2; i++ {
+		rc := &recordConn{}
+		conn := wrapWriteMode(&config.Config{WriteMode: "random-split:42"}, rc)
+		conn.Write(data)
+
+		if !bytes.Equal(bytes.Join(rc.writes, nil), data) {
+			t.Errorf("random-split - expect: %q, got: %q", data, bytes.Join(rc.writes, nil))
+		}
+		splits = append(splits, rc.writes)
+	}
+
+	if !reflect.DeepEqual(splits[0], splits[1]) {
+		t.Errorf("random-split - expect: same chunks, got: %q and %q", splits[0], splits[1])
+	}
+}
@@ -0,0 +1,52 @@
+package stress
+
+import (
+	"golang.org/x/net/http2"
+
+	"github.com/summerwind/h2spec/config"
+	"github.com/summerwind/h2spec/spec"
+)
+
+func RapidReset() *spec.TestGroup {
+	tg := NewTestGroup("1", "Rapid Reset")
+
+	// CVE-2023-44487:
+	// A client opens streams and cancels them immediately with
+	// RST_STREAM frames. The number of concurrently open streams
+	// never exceeds the limit, but the server keeps doing work for
+	// every cancelled request.
+	tg.AddTestCase(&spec.TestCase{
+		Desc:        "Sends HEADERS frames immediately followed by RST_STREAM frames",
+		Requirement: "The endpoint MUST keep responding or send a GOAWAY frame with ENHANCE_YOUR_CALM.",
+		Timeout:     floodTimeout,
+		Run: func(c *config.Config, conn *spec.Conn) error {
+			err := conn.Handshake()
+			if err != nil {
+				return err
+			}
+
+			headers := spec.CommonHeaders(c)
+
+			flood(c, conn, 10000, func(i int) error {
+				streamID := uint32(i*2 + 1)
+
+				hp := http2.HeadersFrameParam{
+					StreamID:      streamID,
+					EndStream:     true,
+					EndHeaders:    true,
+					BlockFragment: conn.EncodeHeaders(headers),
+				}
+				err := conn.WriteHeaders(hp)
+				if err != nil {
+					return err
+				}
+
+				return conn.WriteRSTStream(streamID, http2.ErrCodeCancel)
+			})
+
+			return verifyResponsive(conn)
+		},
+	})
+
+	return tg
+}
@@ -0,0 +1,94 @@
+package stress
+
+import (
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+
+	"github.com/summerwind/h2spec/config"
+	"github.com/summerwind/h2spec/spec"
+)
+
+func ContinuationFlood() *spec.TestGroup {
+	tg := NewTestGroup("2", "CONTINUATION Flood")
+
+	// Some servers limit the number of CONTINUATION frames and treat
+	// the excess as a connection error of type PROTOCOL_ERROR.
+	codes := []http2.ErrCode{
+		http2.ErrCodeEnhanceYourCalm,
+		http2.ErrCodeProtocol,
+	}
+
+	// A header block can be split into any number of CONTINUATION
+	// frames. A server that buffers the header block until
+	// END_HEADERS flag arrives may run out of memory.
+	tg.AddTestCase(&spec.TestCase{
+		Desc:        "Sends a large number of CONTINUATION frames",
+		Requirement: "The endpoint MUST keep responding or send a GOAWAY frame with ENHANCE_YOUR_CALM or PROTOCOL_ERROR.",
+		Timeout:     floodTimeout,
+		Run: func(c *config.Config, conn *spec.Conn) error {
+			var streamID uint32 = 1
+
+			err := conn.Handshake()
+			if err != nil {
+				return err
+			}
+
+			headers := spec.CommonHeaders(c)
+			hp := http2.HeadersFrameParam{
+				StreamID:      streamID,
+				EndStream:     true,
+				EndHeaders:    false,
+				BlockFragment: conn.EncodeHeaders(headers),
+			}
+			conn.WriteHeaders(hp)
+
+			// Sensitive header field is never indexed, so that each
+			// CONTINUATION frame contains the whole header field.
+			dummy := spec.HeaderField("x-dummy", spec.DummyString(1000))
+			dummy.Sensitive = true
+
+			count := 10000
+			flood(c, conn, count, func(i int) error {
+				block := conn.EncodeHeaders([]hpack.HeaderField{dummy})
+				return conn.WriteContinuation(streamID, i == count-1, block)
+			})
+
+			return verifyResponsive(conn, codes...)
+		},
+	})
+
+	// CVE-2024-27316:
+	// Empty CONTINUATION frames do not increase the size of the header
+	// block, so a limit of the header list size is never reached.
+	tg.AddTestCase(&spec.TestCase{
+		Desc:        "Sends a large number of empty CONTINUATION frames",
+		Requirement: "The endpoint MUST keep responding or send a GOAWAY frame with ENHANCE_YOUR_CALM or PROTOCOL_ERROR.",
+		Timeout:     floodTimeout,
+		Run: func(c *config.Config, conn *spec.Conn) error {
+			var streamID uint32 = 1
+
+			err := conn.Handshake()
+			if err != nil {
+				return err
+			}
+
+			headers := spec.CommonHeaders(c)
+			hp := http2.HeadersFrameParam{
+				StreamID:      streamID,
+				EndStream:     true,
+				EndHeaders:    false,
+				BlockFragment: conn.EncodeHeaders(headers),
+			}
+			conn.WriteHeaders(hp)
+
+			count := 100000
+			flood(c, conn, count, func(i int) error {
+				return conn.WriteContinuation(streamID, i == count-1, []byte{})
+			})
+
+			return verifyResponsive(conn, codes...)
+		},
+	})
+
+	return tg
+}
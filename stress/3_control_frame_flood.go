@@ -0,0 +1,65 @@
+package stress
+
+import (
+	"encoding/binary"
+
+	"golang.org/x/net/http2"
+
+	"github.com/summerwind/h2spec/config"
+	"github.com/summerwind/h2spec/spec"
+)
+
+func ControlFrameFlood() *spec.TestGroup {
+	tg := NewTestGroup("3", "Control Frame Flood")
+
+	// CVE-2019-9515:
+	// Every SETTINGS frame must be acknowledged. A client that does
+	// not read the acknowledgements makes the server queue them.
+	tg.AddTestCase(&spec.TestCase{
+		Desc:        "Sends a large number of SETTINGS frames",
+		Requirement: "The endpoint MUST keep responding or send a GOAWAY frame with ENHANCE_YOUR_CALM.",
+		Timeout:     floodTimeout,
+		Run: func(c *config.Config, conn *spec.Conn) error {
+			err := conn.Handshake()
+			if err != nil {
+				return err
+			}
+
+			setting := http2.Setting{
+				ID:  http2.SettingInitialWindowSize,
+				Val: spec.DefaultWindowSize,
+			}
+
+			flood(c, conn, 10000, func(i int) error {
+				return conn.WriteSettings(setting)
+			})
+
+			return verifyResponsive(conn)
+		},
+	})
+
+	// CVE-2019-9512:
+	// Every PING frame must be answered. A client that does not read
+	// the responses makes the server queue them.
+	tg.AddTestCase(&spec.TestCase{
+		Desc:        "Sends a large number of PING frames",
+		Requirement: "The endpoint MUST keep responding or send a GOAWAY frame with ENHANCE_YOUR_CALM.",
+		Timeout:     floodTimeout,
+		Run: func(c *config.Config, conn *spec.Conn) error {
+			err := conn.Handshake()
+			if err != nil {
+				return err
+			}
+
+			flood(c, conn, 10000, func(i int) error {
+				var data [8]byte
+				binary.BigEndian.PutUint64(data[:], uint64(i))
+				return conn.WritePing(false, data)
+			})
+
+			return verifyResponsive(conn)
+		},
+	})
+
+	return tg
+}
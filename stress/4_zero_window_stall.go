@@ -0,0 +1,57 @@
+package stress
+
+import (
+	"golang.org/x/net/http2"
+
+	"github.com/summerwind/h2spec/config"
+	"github.com/summerwind/h2spec/spec"
+)
+
+func ZeroWindowStall() *spec.TestGroup {
+	tg := NewTestGroup("4", "Zero Window Stall")
+
+	// CVE-2019-9517:
+	// A client that advertises a zero flow-control window makes the
+	// server hold the responses of all open streams.
+	tg.AddTestCase(&spec.TestCase{
+		Desc:        "Sends requests with the initial window size set to 0",
+		Requirement: "The endpoint MUST keep responding or send a GOAWAY frame with ENHANCE_YOUR_CALM.",
+		Timeout:     floodTimeout,
+		Run: func(c *config.Config, conn *spec.Conn) error {
+			err := conn.Handshake()
+			if err != nil {
+				return err
+			}
+
+			setting := http2.Setting{
+				ID:  http2.SettingInitialWindowSize,
+				Val: 0,
+			}
+			conn.WriteSettings(setting)
+
+			// Open as many streams as the server allows so that none
+			// of them is refused.
+			count := 1000
+			maxStreams, ok := conn.Settings[http2.SettingMaxConcurrentStreams]
+			if ok && int(maxStreams) < count {
+				count = int(maxStreams)
+			}
+
+			headers := spec.CommonHeaders(c)
+
+			flood(c, conn, count, func(i int) error {
+				hp := http2.HeadersFrameParam{
+					StreamID:      uint32(i*2 + 1),
+					EndStream:     true,
+					EndHeaders:    true,
+					BlockFragment: conn.EncodeHeaders(headers),
+				}
+				return conn.WriteHeaders(hp)
+			})
+
+			return verifyResponsive(conn)
+		},
+	})
+
+	return tg
+}
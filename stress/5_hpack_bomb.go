@@ -0,0 +1,49 @@
+package stress
+
+import (
+	"time"
+
+	"github.com/summerwind/h2spec/config"
+	"github.com/summerwind/h2spec/spec"
+)
+
+func HPACKBomb() *spec.TestGroup {
+	tg := NewTestGroup("5", "HPACK Bomb")
+
+	// A header field that fills the dynamic table can be referenced
+	// by an indexed representation of one octet. A small header block
+	// then decodes to a huge header list.
+	tg.AddTestCase(&spec.TestCase{
+		Desc:        "Sends a header block that references a large dynamic table entry repeatedly",
+		Requirement: "The endpoint MUST keep responding or send a GOAWAY frame with ENHANCE_YOUR_CALM.",
+		Timeout:     floodTimeout,
+		Run: func(c *config.Config, conn *spec.Conn) error {
+			var streamID uint32 = 1
+
+			err := conn.Handshake()
+			if err != nil {
+				return err
+			}
+
+			// The size of an entry is the sum of its name's length, its
+			// value's length, and 32.
+			name := "x-dummy"
+			bomb := spec.HeaderField(name, spec.DummyString(4096-32-len(name)))
+
+			// The encoder adds the first field to the dynamic table and
+			// encodes the rest as indexed representations.
+			headers := spec.CommonHeaders(c)
+			for i := 0; i < 10000; i++ {
+				headers = append(headers, bomb)
+			}
+
+			conn.SetWriteDeadline(time.Now().Add(c.Timeout))
+			writeHeaderBlock(conn, streamID, true, conn.EncodeHeaders(headers))
+			conn.SetWriteDeadline(time.Time{})
+
+			return verifyResponsive(conn)
+		},
+	})
+
+	return tg
+}
@@ -0,0 +1,112 @@
+package stress
+
+import (
+	"time"
+
+	"golang.org/x/net/http2"
+
+	"github.com/summerwind/h2spec/config"
+	"github.com/summerwind/h2spec/spec"
+)
+
+var key = "stress"
+
+func NewTestGroup(section string, name string) *spec.TestGroup {
+	return &spec.TestGroup{
+		Key:     key,
+		Section: section,
+		Name:    name,
+	}
+}
+
+func Spec() *spec.TestGroup {
+	tg := &spec.TestGroup{
+		Key:  key,
+		Name: "Stress tests for HTTP/2 server",
+	}
+
+	tg.AddTestGroup(RapidReset())
+	tg.AddTestGroup(ContinuationFlood())
+	tg.AddTestGroup(ControlFrameFlood())
+	tg.AddTestGroup(ZeroWindowStall())
+	tg.AddTestGroup(HPACKBomb())
+
+	return tg
+}
+
+// floodTimeout is the timeout of the stress test cases. The server may
+// take a while to work through a flood before it responds again.
+const floodTimeout = 5 * time.Second
+
+// pingData is the opaque data of the PING frame that is sent after
+// each flood to check whether the server is still responsive.
+var pingData = [8]byte{'h', '2', 's', 'p', 'e', 'c'}
+
+// flood calls write the specified number of times. Each write has a
+// deadline so that a server that stops reading from the connection
+// cannot block the test forever. Flooding stops at the first error.
+// The deadline is cleared afterwards so that it does not apply to the
+// frames written while verifying the server.
+func flood(c *config.Config, conn *spec.Conn, count int, write func(i int) error) {
+	defer conn.SetWriteDeadline(time.Time{})
+
+	for i := 0; i < count; i++ {
+		conn.SetWriteDeadline(time.Now().Add(c.Timeout))
+
+		err := write(i)
+		if err != nil {
+			return
+		}
+	}
+}
+
+// verifyResponsive sends a PING frame and verifies whether the server
+// responds to it or enforces a limit with a GOAWAY frame. The error
+// code of the GOAWAY frame is ENHANCE_YOUR_CALM unless other codes
+// are specified.
+func verifyResponsive(conn *spec.Conn, codes ...http2.ErrCode) error {
+	if len(codes) == 0 {
+		codes = []http2.ErrCode{http2.ErrCodeEnhanceYourCalm}
+	}
+
+	conn.WritePing(false, pingData)
+	return spec.VerifyPingFrameOrGoAwayFrame(conn, pingData, codes...)
+}
+
+// writeHeaderBlock sends the header block as a HEADERS frame followed
+// by CONTINUATION frames that do not exceed the maximum frame size.
+func writeHeaderBlock(conn *spec.Conn, streamID uint32, endStream bool, block []byte) error {
+	max := conn.MaxFrameSize()
+
+	frag := block
+	if len(frag) > max {
+		frag = block[:max]
+	}
+	block = block[len(frag):]
+
+	hp := http2.HeadersFrameParam{
+		StreamID:      streamID,
+		EndStream:     endStream,
+		EndHeaders:    len(block) == 0,
+		BlockFragment: frag,
+	}
+	err := conn.WriteHeaders(hp)
+	if err != nil {
+		return err
+	}
+
+	for len(block) > 0 {
+		frag = block
+		if len(frag) > max {
+			frag = block[:max]
+		}
+		block = block[len(frag):]
+
+		err = conn.WriteContinuation(streamID, len(block) == 0, frag)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
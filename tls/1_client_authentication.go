@@ -0,0 +1,150 @@
+package tls
+
+import (
+	"crypto/tls"
+
+	"github.com/summerwind/h2spec/config"
+	"github.com/summerwind/h2spec/spec"
+)
+
+func ClientAuthentication() *spec.TestGroup {
+	tg := NewTestGroup("1", "Client Authentication")
+
+	// A server that requires a client certificate must not accept
+	// a connection without it.
+	tg.AddTestCase(&spec.TestCase{
+		Desc:        "Sends a request without a client certificate",
+		Requirement: "The endpoint MUST reject the connection without a client certificate.",
+		Run: func(c *config.Config, conn *spec.Conn) error {
+			if c.ClientCertFile == "" {
+				return spec.ErrSkipped
+			}
+
+			conn, err := dial(c, func(tc *tls.Config) {
+				tc.Certificates = nil
+			})
+			if err != nil {
+				// Rejected in the TLS handshake.
+				return verifyCertificateAlert(err)
+			}
+			defer conn.Close()
+
+			// The client certificate of TLS 1.3 is verified after
+			// the handshake of the client.
+			err = writeRequest(c, conn)
+			if err != nil {
+				return verifyCertificateAlert(err)
+			}
+
+			return verifyRejected(conn)
+		},
+	})
+
+	// A server that requires a client certificate must not accept
+	// a certificate that is not issued by a trusted CA.
+	tg.AddTestCase(&spec.TestCase{
+		Desc:        "Sends a request with an untrusted client certificate",
+		Requirement: "The endpoint MUST reject the connection with an untrusted client certificate.",
+		Run: func(c *config.Config, conn *spec.Conn) error {
+			if c.ClientCertFile == "" {
+				return spec.ErrSkipped
+			}
+
+			cert, err := untrustedCertificate()
+			if err != nil {
+				return err
+			}
+
+			conn, err = dial(c, func(tc *tls.Config) {
+				tc.Certificates = []tls.Certificate{cert}
+			})
+			if err != nil {
+				// Rejected in the TLS handshake.
+				return verifyCertificateAlert(err)
+			}
+			defer conn.Close()
+
+			// The client certificate of TLS 1.3 is verified after
+			// the handshake of the client.
+			err = writeRequest(c, conn)
+			if err != nil {
+				return verifyCertificateAlert(err)
+			}
+
+			return verifyRejected(conn)
+		},
+	})
+
+	tg.AddTestCase(&spec.TestCase{
+		Desc:        "Sends a request with a client certificate",
+		Requirement: "The endpoint MUST respond to the request.",
+		Run: func(c *config.Config, conn *spec.Conn) error {
+			if c.ClientCertFile == "" {
+				return spec.ErrSkipped
+			}
+
+			err := writeRequest(c, conn)
+			if err != nil {
+				return err
+			}
+
+			return spec.VerifyHeadersFrame(conn, 1)
+		},
+	})
+
+	// RFC7540, 9.2.1:
+	// A deployment of HTTP/2 over TLS 1.2 MUST disable renegotiation.
+	// An endpoint MUST treat a TLS renegotiation as a connection error
+	// (Section 5.4.1) of type PROTOCOL_ERROR.
+	tg.AddTestCase(&spec.TestCase{
+		Desc:        "Sends a request over TLS 1.2",
+		Requirement: "The endpoint MUST NOT use TLS renegotiation to request a client certificate.",
+		Run: func(c *config.Config, conn *spec.Conn) error {
+			conn, err := dial(c, func(tc *tls.Config) {
+				tc.MaxVersion = tls.VersionTLS12
+				tc.Renegotiation = tls.RenegotiateNever
+			})
+			if err != nil {
+				// TLS 1.2 is not supported.
+				return spec.ErrSkipped
+			}
+			defer conn.Close()
+
+			err = writeRequest(c, conn)
+			if err != nil {
+				return handshakeError(err, "renegotiation", "TLS renegotiation")
+			}
+
+			return verifyResponse(conn, "renegotiation", "TLS renegotiation")
+		},
+	})
+
+	// RFC8740, 3:
+	// HTTP/2 servers MUST NOT send post-handshake TLS 1.3
+	// CertificateRequest messages.
+	tg.AddTestCase(&spec.TestCase{
+		Desc:        "Sends a request over TLS 1.3",
+		Requirement: "The endpoint MUST NOT send post-handshake CertificateRequest messages.",
+		Run: func(c *config.Config, conn *spec.Conn) error {
+			conn, err := dial(c, func(tc *tls.Config) {
+				tc.MinVersion = tls.VersionTLS13
+			})
+			if err != nil {
+				// TLS 1.3 is not supported.
+				return spec.ErrSkipped
+			}
+			defer conn.Close()
+
+			desc := "Post-handshake CertificateRequest"
+
+			err = writeRequest(c, conn)
+			if err != nil {
+				return handshakeError(err, "certificateRequest", desc)
+			}
+
+			return verifyResponse(conn, "certificateRequest", desc)
+		},
+	})
+
+	return tg
+}
@@ -0,0 +1,157 @@
+package tls
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/http2"
+
+	"github.com/summerwind/h2spec/config"
+	"github.com/summerwind/h2spec/spec"
+)
+
+func SessionResumption() *spec.TestGroup {
+	tg := NewTestGroup("2", "Session Resumption")
+
+	// RFC7540, 3.5:
+	// This connection preface starts with a SETTINGS frame, which is
+	// sent by the server as the first frame of each connection. The
+	// state of HTTP/2 connection is not carried over by a resumed TLS
+	// session.
+	tg.AddTestCase(&spec.TestCase{
+		Desc:        "Sends a request on the stream 1 of a resumed session",
+		Requirement: "The endpoint MUST send a SETTINGS frame and respond to the request on the new connection.",
+		Run: func(c *config.Config, conn *spec.Conn) error {
+			conn, err := dialResumed(c, func(conn *spec.Conn) error {
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+
+			err = writeRequest(c, conn)
+			if err != nil {
+				return err
+			}
+
+			return spec.VerifyHeadersFrame(conn, 1)
+		},
+	})
+
+	// RFC7540, 6.5.2:
+	// SETTINGS_INITIAL_WINDOW_SIZE (0x4): Indicates the sender's
+	// initial window size (in octets) for stream-level flow control.
+	// The initial value is 2^16-1 (65,535) octets.
+	//
+	// The setting is omitted from the handshake of the new connection,
+	// so the server can only send the response body if it does not
+	// carry the window size of 0 over from the resumed session.
+	tg.AddTestCase(&spec.TestCase{
+		Desc:        "Sends SETTINGS_INITIAL_WINDOW_SIZE of 0 before the session is resumed",
+		Requirement: "The endpoint MUST use the initial value of the setting on the new connection.",
+		Run: func(c *config.Config, conn *spec.Conn) error {
+			dataLen, err := spec.ServerDataLength(c)
+			if err != nil {
+				return err
+			}
+
+			if dataLen < 1 {
+				return spec.ErrSkipped
+			}
+
+			conn, err = dialResumed(c, func(conn *spec.Conn) error {
+				setting := http2.Setting{
+					ID:  http2.SettingInitialWindowSize,
+					Val: 0,
+				}
+				return conn.WriteSettings(setting)
+			})
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+
+			err = conn.HandshakeWithSettings()
+			if err != nil {
+				return err
+			}
+
+			spec.WriteRequest(c, conn, 1)
+
+			return spec.VerifyEventType(conn, spec.EventDataFrame)
+		},
+	})
+
+	// RFC8470, 5.1:
+	// An intermediary that forwards a request prior to the completion
+	// of the TLS handshake with its client MUST send it with the
+	// Early-Data header field set to "1".
+	//
+	// RFC8470, 5.2:
+	// A 425 (Too Early) status code indicates that the server is
+	// unwilling to risk processing a request that might be replayed.
+	//
+	// The TLS library of h2spec does not support 0-RTT, so this test
+	// case does not send early data. The request is sent on a resumed
+	// TLS 1.3 session after the handshake, as an intermediary forwards
+	// a request that it received in early data. Since the server does
+	// not receive early data, an accepted response is reported as
+	// skipped.
+	tg.AddTestCase(&spec.TestCase{
+		Desc:        "Sends a POST request with Early-Data header field as forwarded by an intermediary",
+		Requirement: "The endpoint MUST either process the request or respond with 425 (Too Early).",
+		Run: func(c *config.Config, conn *spec.Conn) error {
+			conn, err := dialResumed(c, func(conn *spec.Conn) error {
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+
+			// Early data is only available in TLS 1.3.
+			if conn.TLSInfo().Version != tls.VersionName(tls.VersionTLS13) {
+				return spec.ErrSkipped
+			}
+
+			err = conn.Handshake()
+			if err != nil {
+				return err
+			}
+
+			headers := spec.CommonHeaders(c)
+			headers[0].Value = "POST"
+			headers = append(headers, spec.HeaderField("early-data", "1"))
+
+			hp := http2.HeadersFrameParam{
+				StreamID:      1,
+				EndStream:     false,
+				EndHeaders:    true,
+				BlockFragment: conn.EncodeHeaders(headers),
+			}
+			conn.WriteHeaders(hp)
+			conn.WriteData(1, true, []byte("test"))
+
+			status, err := readStatus(conn, 1)
+			if err != nil {
+				return err
+			}
+
+			if status != "425" && !strings.HasPrefix(status, "2") {
+				return &spec.TestError{
+					Expected: []string{
+						"HEADERS Frame (:status: 2xx)",
+						"HEADERS Frame (:status: 425)",
+					},
+					Actual: fmt.Sprintf("HEADERS Frame (:status: %s)", status),
+				}
+			}
+
+			return spec.ErrSkipped
+		},
+	})
+
+	return tg
+}
@@ -0,0 +1,296 @@
+package tls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http2"
+
+	"github.com/summerwind/h2spec/config"
+	"github.com/summerwind/h2spec/spec"
+)
+
+var key = "tls"
+
+func NewTestGroup(section string, name string) *spec.TestGroup {
+	return &spec.TestGroup{
+		Key:     key,
+		Section: section,
+		Name:    name,
+	}
+}
+
+func Spec() *spec.TestGroup {
+	tg := &spec.TestGroup{
+		Key:  key,
+		Name: "TLS tests for HTTP/2 server",
+	}
+
+	tg.AddTestGroup(ClientAuthentication())
+	tg.AddTestGroup(SessionResumption())
+
+	return tg
+}
+
+// dial connects to the server with the TLS configuration of h2spec
+// modified by the specified function.
+func dial(c *config.Config, modify func(tc *tls.Config)) (*spec.Conn, error) {
+	tlsConfig, err := c.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	modify(tlsConfig)
+
+	return spec.DialWithTLSConfig(c, tlsConfig)
+}
+
+// dialResumed connects to the server twice with the same session
+// cache and returns the second connection. The function is called
+// with the first connection after the request is sent so that the
+// state of the connection can be modified before the session is
+// resumed. It returns spec.ErrSkipped if the server does not resume
+// the session.
+func dialResumed(c *config.Config, prepare func(conn *spec.Conn) error) (*spec.Conn, error) {
+	cache := tls.NewLRUClientSessionCache(1)
+	useCache := func(tc *tls.Config) {
+		tc.ClientSessionCache = cache
+	}
+
+	conn, err := dial(c, useCache)
+	if err != nil {
+		return nil, err
+	}
+
+	err = writeRequest(c, conn)
+	if err == nil {
+		err = prepare(conn)
+	}
+	if err == nil {
+		// The session ticket of TLS 1.3 is sent after the handshake,
+		// it is received while waiting for the response.
+		err = spec.VerifyHeadersFrame(conn, 1)
+	}
+	conn.Close()
+
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err = dial(c, useCache)
+	if err != nil {
+		return nil, err
+	}
+
+	if !conn.TLSInfo().Resumed {
+		conn.Close()
+		return nil, spec.ErrSkipped
+	}
+
+	return conn, nil
+}
+
+// writeRequest performs the HTTP/2 handshake and sends a request on
+// the stream 1.
+func writeRequest(c *config.Config, conn *spec.Conn) error {
+	err := conn.Handshake()
+	if err != nil {
+		return err
+	}
+
+	headers := spec.CommonHeaders(c)
+	hp := http2.HeadersFrameParam{
+		StreamID:      1,
+		EndStream:     true,
+		EndHeaders:    true,
+		BlockFragment: conn.EncodeHeaders(headers),
+	}
+
+	return conn.WriteHeaders(hp)
+}
+
+// verifyRejected verifies whether the server rejected the connection
+// with a TLS alert, a connection error, a stream error or by closing
+// the connection instead of responding to the request.
+func verifyRejected(conn *spec.Conn) error {
+	for !conn.Closed {
+		ev := conn.WaitEvent()
+
+		switch event := ev.(type) {
+		case spec.ErrorEvent:
+			return verifyCertificateAlert(event.Error)
+		case spec.ConnectionClosedEvent, spec.GoAwayFrameEvent, spec.RSTStreamFrameEvent:
+			return nil
+		case spec.HeadersFrameEvent, spec.DataFrameEvent, spec.TimeoutEvent:
+			return &spec.TestError{
+				Expected: []string{
+					"TLS alert",
+					spec.ExpectedConnectionClosed,
+					"GOAWAY Frame",
+					"RST_STREAM Frame",
+				},
+				Actual: ev.String(),
+			}
+		}
+	}
+
+	return nil
+}
+
+// readStatus waits for the final response on the stream and returns
+// its status. Interim responses are skipped.
+func readStatus(conn *spec.Conn, streamID uint32) (string, error) {
+	expected := []string{fmt.Sprintf("HEADERS Frame (stream_id:%d)", streamID)}
+
+	var block []byte
+	for !conn.Closed {
+		ev := conn.WaitEvent()
+
+		switch event := ev.(type) {
+		case spec.HeadersFrameEvent:
+			if event.Header().StreamID != streamID {
+				continue
+			}
+			block = append(block, event.HeaderBlockFragment()...)
+			if !event.HeadersEnded() {
+				continue
+			}
+		case spec.ContinuationFrameEvent:
+			if event.Header().StreamID != streamID {
+				continue
+			}
+			block = append(block, event.HeaderBlockFragment()...)
+			if !event.HeadersEnded() {
+				continue
+			}
+		case spec.RSTStreamFrameEvent, spec.GoAwayFrameEvent, spec.TimeoutEvent, spec.ConnectionClosedEvent, spec.ErrorEvent:
+			return "", &spec.TestError{Expected: expected, Actual: ev.String()}
+		default:
+			continue
+		}
+
+		status := conn.DecodeStatus(block)
+		block = nil
+
+		if !strings.HasPrefix(status, "1") {
+			return status, nil
+		}
+	}
+
+	return "", &spec.TestError{
+		Expected: expected,
+		Actual:   spec.ExpectedConnectionClosed,
+	}
+}
+
+// certificateAlerts are the descriptions of the TLS alerts with which
+// a server rejects a client certificate.
+var certificateAlerts = []string{
+	"bad certificate",
+	"unsupported certificate",
+	"certificate revoked",
+	"certificate expired",
+	"certificate unknown",
+	"unknown certificate authority",
+	"access denied",
+	"handshake failure",
+	"certificate required",
+}
+
+// verifyCertificateAlert verifies whether the error of the client was
+// caused by a TLS alert that rejects the client certificate. Other
+// errors, such as a refused connection, are not a rejection.
+func verifyCertificateAlert(err error) error {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "remote error" {
+		for _, alert := range certificateAlerts {
+			if strings.Contains(opErr.Err.Error(), alert) {
+				return nil
+			}
+		}
+	}
+
+	return &spec.TestError{
+		Expected: []string{"TLS alert (bad_certificate, certificate_required, unknown_ca, ...)"},
+		Actual:   err.Error(),
+	}
+}
+
+// verifyResponse verifies whether the server responds to the request.
+// If the error of the client contains forbidden, the server sent a TLS
+// message described by desc.
+func verifyResponse(conn *spec.Conn, forbidden, desc string) error {
+	expected := []string{"HEADERS Frame (stream_id:1)"}
+
+	for !conn.Closed {
+		ev := conn.WaitEvent()
+
+		switch event := ev.(type) {
+		case spec.HeadersFrameEvent:
+			if event.Header().StreamID == 1 {
+				return nil
+			}
+		case spec.ErrorEvent:
+			actual := ev.String()
+			if strings.Contains(event.Error.Error(), forbidden) {
+				actual = fmt.Sprintf("%s (%v)", desc, event.Error)
+			}
+			return &spec.TestError{Expected: expected, Actual: actual}
+		case spec.TimeoutEvent, spec.ConnectionClosedEvent:
+			return &spec.TestError{Expected: expected, Actual: ev.String()}
+		}
+	}
+
+	return &spec.TestError{
+		Expected: expected,
+		Actual:   spec.ExpectedConnectionClosed,
+	}
+}
+
+// handshakeError returns a test error if the error of the HTTP/2
+// handshake was caused by the TLS message described by desc.
+func handshakeError(err error, forbidden, desc string) error {
+	if !strings.Contains(err.Error(), forbidden) {
+		return err
+	}
+
+	return &spec.TestError{
+		Expected: []string{"HEADERS Frame (stream_id:1)"},
+		Actual:   fmt.Sprintf("%s (%v)", desc, err),
+	}
+}
+
+// untrustedCertificate returns a self-signed client certificate that
+// is generated for each call.
+func untrustedCertificate() (tls.Certificate, error) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	tmpl := x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "h2spec untrusted client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, &priv.PublicKey, priv)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: priv}, nil
+}
@@ -0,0 +1,221 @@
+package tls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/summerwind/h2spec/config"
+	"github.com/summerwind/h2spec/spec"
+)
+
+// testCA is a certificate authority that issues the client
+// certificates of the tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "h2spec test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testCA{cert: cert, key: key}
+}
+
+// writeClientCertificate writes a client certificate issued by the CA
+// and its key to files and returns their paths.
+func (ca *testCA) writeClientCertificate(t *testing.T) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "h2spec test client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "client.crt")
+	keyFile := filepath.Join(dir, "client.key")
+
+	err = ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return certFile, keyFile
+}
+
+// respond returns a handler that responds with the status.
+func respond(status int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte("ok"))
+	}
+}
+
+// startTLSServer starts a local HTTP/2 server over TLS with the
+// handler and the TLS configuration modified by the specified function
+// and returns the configuration of h2spec for it.
+func startTLSServer(t *testing.T, h http.HandlerFunc, modify func(tc *tls.Config)) *config.Config {
+	srv := httptest.NewUnstartedServer(h)
+	srv.EnableHTTP2 = true
+	srv.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	srv.TLS = &tls.Config{}
+	modify(srv.TLS)
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	host, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	portNum, _ := strconv.Atoi(port)
+
+	return &config.Config{
+		Host:     host,
+		Port:     portNum,
+		Path:     "/",
+		Timeout:  time.Second,
+		TLS:      true,
+		Insecure: true,
+	}
+}
+
+func TestClientAuthentication(t *testing.T) {
+	ca := newTestCA(t)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	certFile, keyFile := ca.writeClientCertificate(t)
+
+	requireCert := func(tc *tls.Config) {
+		tc.ClientAuth = tls.RequireAndVerifyClientCert
+		tc.ClientCAs = pool
+	}
+	noCert := func(tc *tls.Config) {}
+
+	// A closed port does not reject the certificate with an alert.
+	closed := startTLSServer(t, respond(200), noCert)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed.Port = ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	tests := []struct {
+		name   string
+		c      *config.Config
+		tlsMax string
+		passed bool
+	}{
+		{name: "TLS 1.3 rejected", c: startTLSServer(t, respond(200), requireCert), tlsMax: "1.3", passed: true},
+		{name: "TLS 1.2 rejected", c: startTLSServer(t, respond(200), requireCert), tlsMax: "1.2", passed: true},
+		{name: "accepted", c: startTLSServer(t, respond(200), noCert), tlsMax: "1.3", passed: false},
+		{name: "closed port", c: closed, tlsMax: "1.3", passed: false},
+	}
+
+	for _, tt := range tests {
+		tt.c.TLSMax = tt.tlsMax
+		tt.c.ClientCertFile = certFile
+		tt.c.ClientKeyFile = keyFile
+
+		tg := ClientAuthentication()
+		for i, tc := range tg.Tests[:2] {
+			err := tc.Run(tt.c, nil)
+			if (err == nil) != tt.passed {
+				t.Errorf("%s #%d - expect passed: %v, got: %v", tt.name, i+1, tt.passed, err)
+			}
+		}
+	}
+}
+
+func TestEarlyData(t *testing.T) {
+	noTickets := func(tc *tls.Config) {
+		tc.SessionTicketsDisabled = true
+	}
+	tls12 := func(tc *tls.Config) {
+		tc.MaxVersion = tls.VersionTLS12
+	}
+	tls13 := func(tc *tls.Config) {}
+
+	tests := []struct {
+		name    string
+		c       *config.Config
+		skipped bool
+	}{
+		{name: "not resumed", c: startTLSServer(t, respond(200), noTickets), skipped: true},
+		{name: "TLS 1.2", c: startTLSServer(t, respond(200), tls12), skipped: true},
+		{name: "200", c: startTLSServer(t, respond(200), tls13), skipped: true},
+		{name: "425", c: startTLSServer(t, respond(425), tls13), skipped: true},
+		{name: "500", c: startTLSServer(t, respond(500), tls13), skipped: false},
+	}
+
+	for _, tt := range tests {
+		tg := SessionResumption()
+		err := tg.Tests[2].Run(tt.c, nil)
+
+		if tt.skipped && err != spec.ErrSkipped {
+			t.Errorf("%s - expect: skipped, got: %v", tt.name, err)
+		}
+		if !tt.skipped {
+			if _, ok := err.(*spec.TestError); !ok {
+				t.Errorf("%s - expect: test error, got: %v", tt.name, err)
+			}
+		}
+	}
+}